@@ -0,0 +1,527 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveSchema adds soft deletion to tasks and columns. Archived rows keep
+// their place and relations so they can be restored, and are hidden from the
+// default listings until the retention purge removes them.
+var archiveSchema = []string{
+	`ALTER TABLE public.api_tasks ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ`,
+	`ALTER TABLE public.api_columns ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ`,
+	`CREATE INDEX IF NOT EXISTS api_tasks_archived_idx ON public.api_tasks (archived_at DESC, id DESC) WHERE archived_at IS NOT NULL`,
+	`CREATE INDEX IF NOT EXISTS api_columns_archived_idx ON public.api_columns (archived_at) WHERE archived_at IS NOT NULL`,
+}
+
+// defaultArchiveRetention is how long archived items are kept before the
+// sweeper deletes them for good.
+const defaultArchiveRetention = 30 * 24 * time.Hour
+
+// parseRetentionDays reads ARCHIVE_RETENTION_DAYS; 0 keeps archived items
+// forever.
+func parseRetentionDays(v string) (time.Duration, error) {
+	days, err := strconv.Atoi(v)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("invalid ARCHIVE_RETENTION_DAYS %q", v)
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// archivedFilter handles ?archived= on GET /api/tasks. Archived tasks are
+// hidden unless archived=true (only archived) or archived=all.
+func archivedFilter(where *whereBuilder, q url.Values) error {
+	switch v := strings.ToLower(strings.TrimSpace(q.Get("archived"))); v {
+	case "", "false":
+		where.add("archived_at IS NULL")
+	case "true":
+		where.add("archived_at IS NOT NULL")
+	case "all":
+	default:
+		return fmt.Errorf("invalid archived %q", v)
+	}
+	return nil
+}
+
+// permanentParam reads ?permanent= on DELETE endpoints, which archive unless
+// it is true.
+func permanentParam(q url.Values) (bool, error) {
+	v := strings.TrimSpace(q.Get("permanent"))
+	if v == "" {
+		return false, nil
+	}
+	permanent, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid permanent %q", v)
+	}
+	return permanent, nil
+}
+
+// setTaskArchived archives or restores a task locked by the caller and audits
+// the change. Archiving also ends any live work claim, so the task drops out
+// of the claim queue and the lease sweeper, and stops running timers.
+func setTaskArchived(ctx context.Context, tx *sql.Tx, by actor, t task, archived bool) (task, error) {
+	if archived {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE public.api_task_leases
+			SET released_at = timezone('utc'::text, now()), release_reason = 'archived'
+			WHERE task_id = $1 AND released_at IS NULL`, t.ID,
+		); err != nil {
+			return task{}, err
+		}
+		if err := stopTimers(ctx, tx, t.ID); err != nil {
+			return task{}, err
+		}
+	}
+	out, err := scanTask(tx.QueryRowContext(ctx, `
+		UPDATE public.api_tasks
+		SET archived_at = CASE WHEN $2 THEN timezone('utc'::text, now()) END,
+			version = version + 1, updated_at = timezone('utc'::text, now())
+		WHERE id = $1
+		RETURNING `+taskColumns, t.ID, archived,
+	))
+	if err != nil {
+		return task{}, err
+	}
+	return out, auditTask(ctx, tx, by, &t, &out)
+}
+
+// lockColumn locks a column row ahead of its tasks, matching the order used
+// by lockTask.
+func lockColumn(ctx context.Context, tx *sql.Tx, id string) (column, error) {
+	return scanColumn(tx.QueryRowContext(ctx, `
+		SELECT `+columnColumns+`
+		FROM public.api_columns
+		WHERE id = $1
+		FOR UPDATE`, id,
+	))
+}
+
+// lockColumnTasks locks the tasks that share the column's archive state:
+// the active ones while the column is active, and the ones archived together
+// with it once it has been archived. Tasks archived on their own earlier stay
+// in the archive when the column is restored.
+func lockColumnTasks(ctx context.Context, tx *sql.Tx, columnID string) ([]task, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE column_id = $1
+			AND archived_at IS NOT DISTINCT FROM (SELECT archived_at FROM public.api_columns WHERE id = $1)
+		ORDER BY id
+		FOR UPDATE`, columnID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, t)
+	}
+	return items, rows.Err()
+}
+
+// archiveColumn archives a column together with its active tasks. Both get
+// the same archived_at, which is how restoreColumn finds them again.
+func (s *server) archiveColumn(w http.ResponseWriter, r *http.Request, id string) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := lockColumn(ctx, tx, id)
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if current.ArchivedAt != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "column is already archived"})
+		return
+	}
+	tasks, err := lockColumnTasks(ctx, tx, id)
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	by := actorFromRequest(r)
+	for _, t := range tasks {
+		if _, err := setTaskArchived(ctx, tx, by, t, true); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+	out, err := scanColumn(tx.QueryRowContext(ctx, `
+		UPDATE public.api_columns
+		SET archived_at = timezone('utc'::text, now())
+		WHERE id = $1
+		RETURNING `+columnColumns, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// restoreTask brings an archived task back to its column. Tasks whose column
+// is itself archived come back with the column instead.
+func (s *server) restoreTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	override, err := overrideWIPParam(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := lockTaskIncludingArchived(ctx, tx, id, "")
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if !checkTaskPrecondition(w, r, current) {
+		return
+	}
+	if current.ArchivedAt == nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "task is not archived"})
+		return
+	}
+	if current.ColumnID != nil {
+		var columnArchived bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT archived_at IS NOT NULL
+			FROM public.api_columns
+			WHERE id = $1`, *current.ColumnID,
+		).Scan(&columnArchived); err != nil {
+			writeStoreError(w, err, "column not found")
+			return
+		}
+		if columnArchived {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "task's column is archived, restore the column first"})
+			return
+		}
+	}
+
+	by := actorFromRequest(r)
+	out, err := setTaskArchived(ctx, tx, by, current, false)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := enforceWIP(ctx, tx, by, &current, out, override); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if out.ColumnID != nil {
+		if err := renumberColumn(ctx, tx, *out.ColumnID); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(out))
+	writeJSON(w, http.StatusOK, out)
+}
+
+// restoreColumn brings an archived column back together with the tasks that
+// were archived with it.
+func (s *server) restoreColumn(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "column not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := lockColumn(ctx, tx, id)
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if current.ArchivedAt == nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "column is not archived"})
+		return
+	}
+	tasks, err := lockColumnTasks(ctx, tx, id)
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	by := actorFromRequest(r)
+	for _, t := range tasks {
+		if _, err := setTaskArchived(ctx, tx, by, t, false); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+	out, err := scanColumn(tx.QueryRowContext(ctx, `
+		UPDATE public.api_columns
+		SET archived_at = NULL
+		WHERE id = $1
+		RETURNING `+columnColumns, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if err := renumberColumn(ctx, tx, id); err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// archiveListing is the response of GET /api/archive. Archived columns are
+// few and returned whole; archived tasks are paginated.
+type archiveListing struct {
+	Columns []column    `json:"columns,omitempty"`
+	Tasks   *page[task] `json:"tasks,omitempty"`
+}
+
+// archiveKinds parses ?type= on GET /api/archive.
+func archiveKinds(q url.Values) (columns, tasks bool, err error) {
+	kinds := listParam(q, "type")
+	if len(kinds) == 0 {
+		return true, true, nil
+	}
+	for _, kind := range kinds {
+		switch kind {
+		case "columns":
+			columns = true
+		case "tasks":
+			tasks = true
+		default:
+			return false, false, fmt.Errorf("invalid type %q", kind)
+		}
+	}
+	return columns, tasks, nil
+}
+
+// listArchive browses archived columns and tasks, most recently archived
+// first. ?board_id= narrows both to one board, ?type=tasks|columns picks a
+// kind, and limit/cursor page through the tasks; created_after and
+// created_before bound the archive time.
+func (s *server) listArchive(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	q := r.URL.Query()
+	wantColumns, wantTasks, err := archiveKinds(q)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	boardID := strings.TrimSpace(q.Get("board_id"))
+	if boardID != "" && !isUUID(boardID) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid board_id"})
+		return
+	}
+	limit, err := parseLimit(q)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	where := &whereBuilder{}
+	where.add("archived_at IS NOT NULL")
+	if boardID != "" {
+		where.add("column_id IN (SELECT id FROM public.api_columns WHERE board_id = " + where.arg(boardID) + ")")
+	}
+	if err := where.addPageFilters(q, "archived_at", "id"); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	var out archiveListing
+	if wantColumns {
+		out.Columns = make([]column, 0)
+		err := eachRow(ctx, s.db, func(rows *sql.Rows) error {
+			c, err := scanColumn(rows)
+			out.Columns = append(out.Columns, c)
+			return err
+		}, `
+			SELECT `+columnColumns+`
+			FROM public.api_columns
+			WHERE archived_at IS NOT NULL AND ($1 = '' OR board_id = NULLIF($1, '')::uuid)
+			ORDER BY archived_at DESC, id DESC
+			LIMIT `+strconv.Itoa(maxPageLimit), boardID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if wantTasks {
+		items := make([]task, 0)
+		err := eachRow(ctx, s.db, func(rows *sql.Rows) error {
+			t, err := scanTask(rows)
+			items = append(items, t)
+			return err
+		}, `
+			SELECT `+taskColumns+`
+			FROM public.api_tasks
+			`+where.sql()+`
+			ORDER BY archived_at DESC, id DESC
+			LIMIT `+where.arg(limit+1), where.args...)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		tasks := newPage(items, limit, func(t task) pageCursor {
+			return pageCursor{CreatedAt: *t.ArchivedAt, ID: t.ID}
+		})
+		out.Tasks = &tasks
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// deleteTasks deletes the tasks matching cond and audits each as deleted by
+// by, so tasks going with their column or board leave history and activity
+// rows like any other deletion instead of vanishing in the cascade.
+func deleteTasks(ctx context.Context, tx *sql.Tx, by actor, cond string, args ...any) ([]task, error) {
+	var deleted []task
+	err := eachRow(ctx, tx, func(rows *sql.Rows) error {
+		t, err := scanTask(rows)
+		deleted = append(deleted, t)
+		return err
+	}, `DELETE FROM public.api_tasks
+		WHERE `+cond+`
+		RETURNING `+taskColumns, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range deleted {
+		if err := auditTask(ctx, tx, by, &t, nil); err != nil {
+			return nil, err
+		}
+	}
+	return deleted, nil
+}
+
+// purgeArchive permanently deletes tasks and columns that have been archived
+// for longer than the retention period. Each deleted task is audited as a
+// deletion by the sweeper, including tasks that would otherwise go with
+// their column, and their history rows are kept as for any other deletion.
+func (s *server) purgeArchive(ctx context.Context, retention time.Duration) error {
+	if retention <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	seconds := int64(retention / time.Second)
+	purged, err := deleteTasks(ctx, tx, sweeperActor, `archived_at < now() - $1::bigint * interval '1 second'
+		OR column_id IN (
+			SELECT id FROM public.api_columns
+			WHERE archived_at < now() - $1::bigint * interval '1 second')`, seconds)
+	if err != nil {
+		return err
+	}
+	columns, err := tx.ExecContext(ctx, `
+		DELETE FROM public.api_columns
+		WHERE archived_at < now() - $1::bigint * interval '1 second'`, seconds)
+	if err != nil {
+		return err
+	}
+	taskCount := int64(len(purged))
+	columnCount, _ := columns.RowsAffected()
+	if taskCount == 0 && columnCount == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("purged %d archived tasks and %d archived columns", taskCount, columnCount)
+	if err := insertLogEntry(ctx, tx, nil, "", "INFO", message, map[string]any{
+		"source":         "sweeper",
+		"actions":        []string{"purge"},
+		"tasks":          taskCount,
+		"columns":        columnCount,
+		"retention_days": int(retention / (24 * time.Hour)),
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
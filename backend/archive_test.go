@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestArchivedFilter(t *testing.T) {
+	cases := map[string]string{
+		"":      "WHERE archived_at IS NULL",
+		"false": "WHERE archived_at IS NULL",
+		"true":  "WHERE archived_at IS NOT NULL",
+		"all":   "",
+	}
+	for v, want := range cases {
+		where := &whereBuilder{}
+		if err := archivedFilter(where, url.Values{"archived": {v}}); err != nil {
+			t.Fatalf("archived=%q: %v", v, err)
+		}
+		if got := where.sql(); got != want {
+			t.Errorf("archived=%q: got %q, want %q", v, got, want)
+		}
+	}
+	if err := archivedFilter(&whereBuilder{}, url.Values{"archived": {"maybe"}}); err == nil {
+		t.Fatal("expected an invalid archived value to be rejected")
+	}
+}
+
+func TestArchiveKinds(t *testing.T) {
+	columns, tasks, err := archiveKinds(url.Values{})
+	if err != nil || !columns || !tasks {
+		t.Fatalf("expected both kinds by default, got %v %v %v", columns, tasks, err)
+	}
+	columns, tasks, err = archiveKinds(url.Values{"type": {"tasks"}})
+	if err != nil || columns || !tasks {
+		t.Fatalf("expected only tasks, got %v %v %v", columns, tasks, err)
+	}
+	if _, _, err := archiveKinds(url.Values{"type": {"boards"}}); err == nil {
+		t.Fatal("expected an unknown type to be rejected")
+	}
+}
+
+func TestParseRetentionDays(t *testing.T) {
+	if d, err := parseRetentionDays("7"); err != nil || d != 7*24*time.Hour {
+		t.Fatalf("expected 7 days, got %v %v", d, err)
+	}
+	if d, err := parseRetentionDays("0"); err != nil || d != 0 {
+		t.Fatalf("expected 0 to disable the purge, got %v %v", d, err)
+	}
+	for _, v := range []string{"-1", "1w", ""} {
+		if _, err := parseRetentionDays(v); err == nil {
+			t.Errorf("expected %q to be rejected", v)
+		}
+	}
+}
+
+func TestDiffTaskRecordsArchiveAndRestore(t *testing.T) {
+	now := time.Now()
+	active := task{ID: "t1", Title: "Ship"}
+	archived := active
+	archived.ArchivedAt = &now
+
+	if c := diffTask(&active, &archived); len(c) != 1 || c[0].Action != "archive" {
+		t.Fatalf("expected an archive change, got %+v", c)
+	}
+	if c := diffTask(&archived, &active); len(c) != 1 || c[0].Action != "restore" {
+		t.Fatalf("expected a restore change, got %+v", c)
+	}
+}
+
+func TestPurgeArchiveAuditsDeletedTasks(t *testing.T) {
+	f, db := newFakeDB(t)
+	archived := time.Now().Add(-60 * 24 * time.Hour)
+	f.answer("DELETE FROM public.api_tasks", taskRowColumns,
+		taskRow(task{ID: "t1", Title: "Old", Status: "done", ArchivedAt: &archived}),
+		taskRow(task{ID: "t2", Title: "Older", Status: "todo", ArchivedAt: &archived}))
+
+	s := &server{db: db}
+	if err := s.purgeArchive(context.Background(), 30*24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	history := f.find("INSERT INTO public.api_task_history")
+	if len(history) != 2 {
+		t.Fatalf("expected a history entry per purged task, got %+v", history)
+	}
+	for i, h := range history {
+		if h.Tx == 0 || h.Args[0] != []string{"t1", "t2"}[i] || h.Args[1] != "delete" || h.Args[3] != sweeperActor.ID {
+			t.Errorf("unexpected history entry %+v", h)
+		}
+	}
+	if logs := f.find("INSERT INTO public.api_logs"); len(logs) != 3 {
+		t.Errorf("expected an activity row per task and a summary, got %d", len(logs))
+	}
+}
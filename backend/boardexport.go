@@ -0,0 +1,749 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	boardExportFormat  = "hq-board"
+	boardExportVersion = 1
+
+	// maxImportBytes and maxImportTasks bound a single import; large Trello
+	// boards export a few megabytes of JSON.
+	maxImportBytes = 32 << 20
+	maxImportTasks = 5000
+)
+
+// boardExport is the self-contained document produced by
+// GET /api/boards/{id}/export and accepted by POST /api/boards/import. Rows
+// refer to each other by key (the id in the source database); the order of
+// columns and of tasks within a column is the order of the arrays.
+type boardExport struct {
+	Format     string           `json:"format"`
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Board      exportedBoard    `json:"board"`
+	Columns    []exportedColumn `json:"columns"`
+	Labels     []exportedLabel  `json:"labels"`
+	Tasks      []exportedTask   `json:"tasks"`
+}
+
+type exportedBoard struct {
+	Name    string  `json:"name"`
+	OwnerID *string `json:"owner_id,omitempty"`
+}
+
+type exportedColumn struct {
+	Key              string `json:"key"`
+	Title            string `json:"title"`
+	WIPLimit         *int   `json:"wip_limit,omitempty"`
+	WIPLimitPerAgent *int   `json:"wip_limit_per_agent,omitempty"`
+}
+
+type exportedLabel struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type exportedTask struct {
+	Key         string                  `json:"key"`
+	Column      string                  `json:"column"`
+	Parent      *string                 `json:"parent,omitempty"`
+	Title       string                  `json:"title"`
+	Description string                  `json:"description"`
+	Status      string                  `json:"status"`
+	AssignedTo  *string                 `json:"assigned_to,omitempty"`
+	DueAt       *time.Time              `json:"due_at,omitempty"`
+	Labels      []string                `json:"labels"`
+	BlockedBy   []string                `json:"blocked_by"`
+	Checklist   []exportedChecklistItem `json:"checklist"`
+	Comments    []exportedComment       `json:"comments"`
+	CreatedAt   *time.Time              `json:"created_at,omitempty"`
+}
+
+type exportedChecklistItem struct {
+	Title string `json:"title"`
+	Done  bool   `json:"done"`
+}
+
+type exportedComment struct {
+	Author    actor      `json:"author"`
+	Body      string     `json:"body"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// importSummary is the response of POST /api/boards/import. A dry run
+// reports the same counts and warnings without writing anything.
+type importSummary struct {
+	DryRun         bool     `json:"dry_run"`
+	Source         string   `json:"source"`
+	Board          *board   `json:"board,omitempty"`
+	Name           string   `json:"name"`
+	Columns        int      `json:"columns"`
+	Labels         int      `json:"labels"`
+	Tasks          int      `json:"tasks"`
+	ChecklistItems int      `json:"checklist_items"`
+	Comments       int      `json:"comments"`
+	Dependencies   int      `json:"dependencies"`
+	Warnings       []string `json:"warnings"`
+}
+
+func (s *server) exportBoard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	out, err := loadBoardExport(ctx, tx, s.taskWorkflow(), id)
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="board-%s.json"`, id))
+	writeJSON(w, http.StatusOK, out)
+}
+
+func loadBoardExport(ctx context.Context, tx *sql.Tx, wf *workflow, id string) (boardExport, error) {
+	view, err := loadBoardView(ctx, tx, wf, id)
+	if err != nil {
+		return boardExport{}, err
+	}
+	out := boardExport{
+		Format:     boardExportFormat,
+		Version:    boardExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Board:      exportedBoard{Name: view.Name, OwnerID: view.OwnerID},
+		Columns:    make([]exportedColumn, 0, len(view.Columns)),
+		Labels:     make([]exportedLabel, 0),
+		Tasks:      make([]exportedTask, 0),
+	}
+
+	onBoard := make(map[string]int)
+	for _, c := range view.Columns {
+		out.Columns = append(out.Columns, exportedColumn{Key: c.ID, Title: c.Title, WIPLimit: c.WIPLimit, WIPLimitPerAgent: c.WIPLimitPerAgent})
+		for _, t := range c.Tasks {
+			created := t.CreatedAt
+			et := exportedTask{
+				Key:         t.ID,
+				Column:      c.ID,
+				Parent:      t.ParentID,
+				Title:       t.Title,
+				Description: t.Description,
+				Status:      t.Status,
+				AssignedTo:  t.AssignedTo,
+				DueAt:       t.DueAt,
+				Labels:      make([]string, 0, len(t.Labels)),
+				BlockedBy:   make([]string, 0),
+				Checklist:   make([]exportedChecklistItem, 0),
+				Comments:    make([]exportedComment, 0),
+				CreatedAt:   &created,
+			}
+			for _, l := range t.Labels {
+				et.Labels = append(et.Labels, l.ID)
+			}
+			onBoard[t.ID] = len(out.Tasks)
+			out.Tasks = append(out.Tasks, et)
+		}
+	}
+	// Parents on another board do not travel with the export.
+	for i, t := range out.Tasks {
+		if t.Parent != nil {
+			if _, ok := onBoard[*t.Parent]; !ok {
+				out.Tasks[i].Parent = nil
+			}
+		}
+	}
+
+	labelRows, err := tx.QueryContext(ctx, `
+		SELECT `+labelColumns+`
+		FROM public.api_labels
+		WHERE board_id = $1
+		ORDER BY lower(name) ASC, id ASC`, id)
+	if err != nil {
+		return boardExport{}, err
+	}
+	for labelRows.Next() {
+		l, err := scanLabel(labelRows)
+		if err != nil {
+			labelRows.Close()
+			return boardExport{}, err
+		}
+		out.Labels = append(out.Labels, exportedLabel{Key: l.ID, Name: l.Name, Color: l.Color})
+	}
+	labelRows.Close()
+	if err := labelRows.Err(); err != nil {
+		return boardExport{}, err
+	}
+
+	taskIDs := make([]string, 0, len(onBoard))
+	for id := range onBoard {
+		taskIDs = append(taskIDs, id)
+	}
+	if len(taskIDs) == 0 {
+		return out, nil
+	}
+
+	err = eachRow(ctx, tx, func(rows *sql.Rows) error {
+		var taskID string
+		var item exportedChecklistItem
+		if err := rows.Scan(&taskID, &item.Title, &item.Done); err != nil {
+			return err
+		}
+		t := &out.Tasks[onBoard[taskID]]
+		t.Checklist = append(t.Checklist, item)
+		return nil
+	}, `
+		SELECT task_id::text, title, done
+		FROM public.api_checklist_items
+		WHERE task_id = ANY($1::uuid[])
+		ORDER BY position ASC, created_at ASC`, taskIDs)
+	if err != nil {
+		return boardExport{}, err
+	}
+
+	err = eachRow(ctx, tx, func(rows *sql.Rows) error {
+		c, err := scanComment(rows)
+		if err != nil {
+			return err
+		}
+		t := &out.Tasks[onBoard[c.TaskID]]
+		t.Comments = append(t.Comments, exportedComment{Author: c.Author, Body: c.Body, CreatedAt: &c.CreatedAt})
+		return nil
+	}, `
+		SELECT `+commentColumns+`
+		FROM public.api_task_comments
+		WHERE task_id = ANY($1::uuid[])
+		ORDER BY created_at ASC, id ASC`, taskIDs)
+	if err != nil {
+		return boardExport{}, err
+	}
+
+	err = eachRow(ctx, tx, func(rows *sql.Rows) error {
+		var taskID, blockerID string
+		if err := rows.Scan(&taskID, &blockerID); err != nil {
+			return err
+		}
+		t := &out.Tasks[onBoard[taskID]]
+		t.BlockedBy = append(t.BlockedBy, blockerID)
+		return nil
+	}, `
+		SELECT task_id::text, blocked_by_id::text
+		FROM public.api_task_dependencies
+		WHERE task_id = ANY($1::uuid[]) AND blocked_by_id = ANY($1::uuid[])
+		ORDER BY created_at ASC, blocked_by_id ASC`, taskIDs)
+	if err != nil {
+		return boardExport{}, err
+	}
+	return out, nil
+}
+
+// eachRow runs query and calls fn for every row.
+func eachRow(ctx context.Context, q queryer, fn func(*sql.Rows) error, query string, args ...any) error {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// decodeBoardImport recognises the document format of an import body and
+// converts it to a boardExport.
+func decodeBoardImport(data []byte, wf *workflow) (*boardExport, string, error) {
+	var probe struct {
+		Format  *string          `json:"format"`
+		Version int              `json:"version"`
+		Lists   *json.RawMessage `json:"lists"`
+		Cards   *json.RawMessage `json:"cards"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, "", errors.New("invalid json")
+	}
+	switch {
+	case probe.Format != nil:
+		if *probe.Format != boardExportFormat {
+			return nil, "", fmt.Errorf("unknown format %q", *probe.Format)
+		}
+		if probe.Version < 1 || probe.Version > boardExportVersion {
+			return nil, "", fmt.Errorf("unsupported %s version %d", boardExportFormat, probe.Version)
+		}
+		var doc boardExport
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, "", fmt.Errorf("invalid %s document: %v", boardExportFormat, err)
+		}
+		return &doc, "hq", nil
+	case probe.Lists != nil && probe.Cards != nil:
+		var tb trelloBoard
+		if err := json.Unmarshal(data, &tb); err != nil {
+			return nil, "", fmt.Errorf("invalid Trello export: %v", err)
+		}
+		return tb.toBoardExport(wf), "trello", nil
+	}
+	return nil, "", errors.New("unrecognised document: expected an hq-board export or a Trello JSON export")
+}
+
+// normalize validates doc in place, repairing what can be repaired and
+// describing every repair as a warning. Malformed structure, such as
+// duplicate keys, is an error.
+func (doc *boardExport) normalize(wf *workflow) ([]string, error) {
+	warnings := make([]string, 0)
+	warn := func(format string, args ...any) {
+		warnings = append(warnings, fmt.Sprintf(format, args...))
+	}
+
+	doc.Board.Name = strings.TrimSpace(doc.Board.Name)
+	if doc.Board.Name == "" {
+		doc.Board.Name = "Imported board"
+	}
+	if len(doc.Tasks) > maxImportTasks {
+		return nil, fmt.Errorf("at most %d tasks can be imported at once", maxImportTasks)
+	}
+
+	columns := make(map[string]bool, len(doc.Columns))
+	for i := range doc.Columns {
+		c := &doc.Columns[i]
+		if c.Key == "" {
+			c.Key = "column-" + strconv.Itoa(i)
+		}
+		if columns[c.Key] {
+			return nil, fmt.Errorf("duplicate column key %q", c.Key)
+		}
+		columns[c.Key] = true
+		if c.Title = strings.TrimSpace(c.Title); c.Title == "" {
+			c.Title = "Untitled"
+		}
+		for _, limit := range []**int{&c.WIPLimit, &c.WIPLimitPerAgent} {
+			if *limit != nil && **limit <= 0 {
+				warn("column %q: WIP limit %d is not positive and was dropped", c.Title, **limit)
+				*limit = nil
+			}
+		}
+	}
+	if len(doc.Columns) == 0 && len(doc.Tasks) > 0 {
+		doc.Columns = []exportedColumn{{Key: "column-0", Title: "Imported"}}
+		columns["column-0"] = true
+		warn("the document has no columns; tasks are placed in a new column %q", "Imported")
+	}
+
+	// Label names are unique per board, so same-named labels are merged.
+	labelKeys := make(map[string]string, len(doc.Labels))
+	byName := make(map[string]string)
+	labels := doc.Labels[:0]
+	for _, l := range doc.Labels {
+		if _, ok := labelKeys[l.Key]; ok || l.Key == "" {
+			return nil, fmt.Errorf("duplicate or missing label key %q", l.Key)
+		}
+		l.Name = strings.TrimSpace(l.Name)
+		if l.Name == "" {
+			warn("skipped a label without a name")
+			labelKeys[l.Key] = ""
+			continue
+		}
+		if utf8.RuneCountInString(l.Name) > maxLabelNameLength {
+			l.Name = string([]rune(l.Name)[:maxLabelNameLength])
+			warn("label name truncated to %q", l.Name)
+		}
+		if key, ok := byName[strings.ToLower(l.Name)]; ok {
+			labelKeys[l.Key] = key
+			warn("merged duplicate label %q", l.Name)
+			continue
+		}
+		l.Color = strings.ToLower(strings.TrimSpace(l.Color))
+		if validateLabelColor(l.Color) != nil {
+			if l.Color != "" {
+				warn("label %q has an invalid colour %q; using the default", l.Name, l.Color)
+			}
+			l.Color = defaultLabelColor
+		}
+		labelKeys[l.Key] = l.Key
+		byName[strings.ToLower(l.Name)] = l.Key
+		labels = append(labels, l)
+	}
+	doc.Labels = labels
+
+	tasks := make(map[string]bool, len(doc.Tasks))
+	for _, t := range doc.Tasks {
+		if t.Key == "" || tasks[t.Key] {
+			return nil, fmt.Errorf("duplicate or missing task key %q", t.Key)
+		}
+		tasks[t.Key] = true
+	}
+	kept := doc.Tasks[:0]
+	for _, t := range doc.Tasks {
+		if t.Title = strings.TrimSpace(t.Title); t.Title == "" {
+			warn("skipped task %s without a title", t.Key)
+			delete(tasks, t.Key)
+			continue
+		}
+		t.Description = strings.TrimSpace(t.Description)
+		if !columns[t.Column] {
+			warn("task %q refers to an unknown column; placed in %q", t.Title, doc.Columns[0].Title)
+			t.Column = doc.Columns[0].Key
+		}
+		if t.Status = strings.TrimSpace(t.Status); t.Status == "" {
+			t.Status = wf.Initial
+		} else if !wf.hasState(t.Status) {
+			warn("task %q has status %q, which is not in the workflow; set to %q", t.Title, t.Status, wf.Initial)
+			t.Status = wf.Initial
+		}
+		t.AssignedTo = nullableString(trimmedOrEmpty(t.AssignedTo))
+
+		var taskLabels []string
+		for _, key := range t.Labels {
+			mapped, ok := labelKeys[key]
+			switch {
+			case !ok:
+				warn("task %q refers to an unknown label %q", t.Title, key)
+			case mapped != "" && !slices.Contains(taskLabels, mapped):
+				taskLabels = append(taskLabels, mapped)
+			}
+		}
+		t.Labels = taskLabels
+
+		var checklist []exportedChecklistItem
+		for _, item := range t.Checklist {
+			title, err := parseChecklistTitle(item.Title)
+			if err != nil {
+				warn("skipped a checklist item on task %q: %v", t.Title, err)
+				continue
+			}
+			checklist = append(checklist, exportedChecklistItem{Title: title, Done: item.Done})
+		}
+		t.Checklist = checklist
+
+		var comments []exportedComment
+		for _, c := range t.Comments {
+			if c.Body = strings.TrimSpace(c.Body); c.Body == "" {
+				continue
+			}
+			if utf8.RuneCountInString(c.Body) > maxCommentLength {
+				c.Body = string([]rune(c.Body)[:maxCommentLength])
+				warn("truncated a long comment on task %q", t.Title)
+			}
+			if c.Author.ID = strings.TrimSpace(c.Author.ID); c.Author.ID == "" {
+				c.Author = anonymousActor
+			}
+			if c.Author.Type = strings.TrimSpace(c.Author.Type); c.Author.Type == "" {
+				c.Author.Type = "user"
+			}
+			comments = append(comments, c)
+		}
+		t.Comments = comments
+		kept = append(kept, t)
+	}
+	doc.Tasks = kept
+
+	// Links are checked once every task is known: dangling references are
+	// dropped, and so is any link that would close a loop.
+	parents := make(map[string]string)
+	blockedBy := make(map[string][]string)
+	for i := range doc.Tasks {
+		t := &doc.Tasks[i]
+		if t.Parent != nil {
+			switch parent := *t.Parent; {
+			case !tasks[parent] || parent == t.Key:
+				warn("task %q refers to an unknown parent; imported as a top-level task", t.Title)
+				t.Parent = nil
+			case ancestorOf(parents, parent, t.Key):
+				warn("task %q would be nested under its own subtask; imported as a top-level task", t.Title)
+				t.Parent = nil
+			default:
+				parents[t.Key] = parent
+			}
+		}
+	}
+	for i := range doc.Tasks {
+		t := &doc.Tasks[i]
+		var blockers []string
+		for _, key := range t.BlockedBy {
+			switch {
+			case !tasks[key] || key == t.Key || slices.Contains(blockers, key):
+				warn("task %q: dropped a dependency on an unknown task", t.Title)
+			case findPath(blockedBy, key, t.Key) != nil:
+				warn("task %q: dropped a dependency that would create a cycle", t.Title)
+			default:
+				blockers = append(blockers, key)
+				blockedBy[t.Key] = append(blockedBy[t.Key], key)
+			}
+		}
+		t.BlockedBy = blockers
+	}
+	return warnings, nil
+}
+
+// ancestorOf reports whether key appears in the parent chain starting at
+// from, following parents.
+func ancestorOf(parents map[string]string, from, key string) bool {
+	seen := make(map[string]bool)
+	for cur, ok := from, true; ok && !seen[cur]; cur, ok = parents[cur] {
+		if cur == key {
+			return true
+		}
+		seen[cur] = true
+	}
+	return false
+}
+
+// summarize counts what importing doc creates.
+func (doc *boardExport) summarize(source string, warnings []string) importSummary {
+	sum := importSummary{
+		Source:   source,
+		Name:     doc.Board.Name,
+		Columns:  len(doc.Columns),
+		Labels:   len(doc.Labels),
+		Tasks:    len(doc.Tasks),
+		Warnings: warnings,
+	}
+	for _, t := range doc.Tasks {
+		sum.ChecklistItems += len(t.Checklist)
+		sum.Comments += len(t.Comments)
+		sum.Dependencies += len(t.BlockedBy)
+	}
+	return sum
+}
+
+// importBoard creates a new board from an hq-board export or a Trello JSON
+// export. With ?dry_run=true it only reports what would be created; ?name=
+// overrides the board name and ?override_wip=true lets columns start above
+// their exported WIP limits.
+func (s *server) importBoard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	q := r.URL.Query()
+	dryRun := false
+	if v := strings.TrimSpace(q.Get("dry_run")); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid dry_run"})
+			return
+		}
+	}
+	override, err := overrideWIPParam(q)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if s.db == nil && !dryRun {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("import must be at most %d MiB", maxImportBytes>>20)})
+		return
+	}
+	wf := s.taskWorkflow()
+	doc, source, err := decodeBoardImport(data, wf)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if name := strings.TrimSpace(q.Get("name")); name != "" {
+		doc.Board.Name = name
+	}
+	warnings, err := doc.normalize(wf)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	summary := doc.summarize(source, warnings)
+	if dryRun {
+		summary.DryRun = true
+		writeJSON(w, http.StatusOK, summary)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 60*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	b, err := insertBoardExport(ctx, tx, actorFromRequest(r), doc, override)
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	summary.Board = &b
+	writeJSON(w, http.StatusCreated, summary)
+}
+
+// insertBoardExport writes a normalized document as a new board. Every task
+// gets a creation entry in its history and is checked against its column's
+// WIP limits like tasks created through the API.
+func insertBoardExport(ctx context.Context, tx *sql.Tx, by actor, doc *boardExport, override bool) (board, error) {
+	b, err := scanBoard(tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_boards (name, owner_id)
+		VALUES ($1, NULLIF($2, ''))
+		RETURNING id::text, name, owner_id, created_at`, doc.Board.Name, trimmedOrEmpty(doc.Board.OwnerID),
+	))
+	if err != nil {
+		return board{}, err
+	}
+
+	columnIDs := make(map[string]string, len(doc.Columns))
+	for i, c := range doc.Columns {
+		var id string
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO public.api_columns (board_id, title, "order", wip_limit, wip_limit_per_agent)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id::text`, b.ID, c.Title, i, c.WIPLimit, c.WIPLimitPerAgent,
+		).Scan(&id); err != nil {
+			return board{}, err
+		}
+		columnIDs[c.Key] = id
+	}
+
+	labelIDs := make(map[string]string, len(doc.Labels))
+	for _, l := range doc.Labels {
+		var id string
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO public.api_labels (board_id, name, color)
+			VALUES ($1, $2, $3)
+			RETURNING id::text`, b.ID, l.Name, l.Color,
+		).Scan(&id); err != nil {
+			return board{}, err
+		}
+		labelIDs[l.Key] = id
+	}
+
+	perColumn := make(map[string]int)
+	for _, t := range doc.Tasks {
+		perColumn[t.Column]++
+	}
+	ranks := make(map[string][]string, len(perColumn))
+	for key, n := range perColumn {
+		ranks[key] = spreadRanks(n)
+	}
+	placed := make(map[string]int)
+	taskIDs := make(map[string]string, len(doc.Tasks))
+	created := make([]string, 0, len(doc.Tasks))
+	for _, t := range doc.Tasks {
+		i := placed[t.Column]
+		placed[t.Column]++
+		var id string
+		if err := tx.QueryRowContext(ctx, `
+			INSERT INTO public.api_tasks (column_id, title, description, status, assigned_to, due_at, "order", rank, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, COALESCE($9, timezone('utc'::text, now())))
+			RETURNING id::text`,
+			columnIDs[t.Column], t.Title, t.Description, t.Status, t.AssignedTo, t.DueAt, i, ranks[t.Column][i], t.CreatedAt,
+		).Scan(&id); err != nil {
+			return board{}, err
+		}
+		columnID := columnIDs[t.Column]
+		if err := enforceWIP(ctx, tx, by, nil, task{ID: id, ColumnID: &columnID, Title: t.Title, AssignedTo: t.AssignedTo}, override); err != nil {
+			return board{}, err
+		}
+		taskIDs[t.Key] = id
+		created = append(created, id)
+	}
+
+	for _, t := range doc.Tasks {
+		id := taskIDs[t.Key]
+		if t.Parent != nil {
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE public.api_tasks SET parent_id = $1 WHERE id = $2`, taskIDs[*t.Parent], id,
+			); err != nil {
+				return board{}, err
+			}
+		}
+		for _, key := range t.Labels {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO public.api_task_labels (task_id, label_id)
+				VALUES ($1, $2)`, id, labelIDs[key],
+			); err != nil {
+				return board{}, err
+			}
+		}
+		for _, key := range t.BlockedBy {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO public.api_task_dependencies (task_id, blocked_by_id)
+				VALUES ($1, $2)`, id, taskIDs[key],
+			); err != nil {
+				return board{}, err
+			}
+		}
+		for i, item := range t.Checklist {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO public.api_checklist_items (task_id, title, done, position)
+				VALUES ($1, $2, $3, $4)`, id, item.Title, item.Done, i,
+			); err != nil {
+				return board{}, err
+			}
+		}
+		for _, c := range t.Comments {
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO public.api_task_comments (task_id, author_type, author_id, body, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, COALESCE($5, timezone('utc'::text, now())), COALESCE($5, timezone('utc'::text, now())))`,
+				id, c.Author.Type, c.Author.ID, c.Body, c.CreatedAt,
+			); err != nil {
+				return board{}, err
+			}
+		}
+	}
+
+	for _, id := range created {
+		t, err := scanTask(tx.QueryRowContext(ctx, `
+			SELECT `+taskColumns+`
+			FROM public.api_tasks
+			WHERE id = $1`, id,
+		))
+		if err != nil {
+			return board{}, err
+		}
+		if err := auditTask(ctx, tx, by, nil, &t); err != nil {
+			return board{}, err
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const trelloFixture = `{
+	"name": "Legacy board",
+	"members": [{"id": "m1", "username": "martha"}],
+	"lists": [
+		{"id": "l2", "name": "Done", "pos": 2048},
+		{"id": "l1", "name": "To Do", "pos": 1024},
+		{"id": "l3", "name": "Old", "pos": 4096, "closed": true}
+	],
+	"labels": [
+		{"id": "lb1", "name": "Bug", "color": "red_dark"},
+		{"id": "lb2", "name": "", "color": "green"}
+	],
+	"cards": [
+		{"id": "5f0c8a000000000000000001", "name": "Second", "idList": "l1", "pos": 2, "idLabels": ["lb1", "lb2"], "idMembers": ["m1"]},
+		{"id": "5f0c8a000000000000000002", "name": "First", "idList": "l1", "pos": 1},
+		{"id": "5f0c8a000000000000000003", "name": "Shipped", "idList": "l2", "pos": 1},
+		{"id": "5f0c8a000000000000000004", "name": "Archived", "idList": "l1", "pos": 3, "closed": true},
+		{"id": "5f0c8a000000000000000005", "name": "In closed list", "idList": "l3", "pos": 1}
+	],
+	"checklists": [
+		{"idCard": "5f0c8a000000000000000001", "pos": 1, "checkItems": [
+			{"name": "b", "state": "complete", "pos": 2},
+			{"name": "a", "state": "incomplete", "pos": 1}
+		]}
+	],
+	"actions": [
+		{"type": "commentCard", "date": "2020-07-14T10:00:00Z", "data": {"text": "newer", "card": {"id": "5f0c8a000000000000000001"}}, "memberCreator": {"username": "martha"}},
+		{"type": "updateCard", "date": "2020-07-14T09:30:00Z", "data": {"card": {"id": "5f0c8a000000000000000001"}}},
+		{"type": "commentCard", "date": "2020-07-14T09:00:00Z", "data": {"text": "older", "card": {"id": "5f0c8a000000000000000001"}}, "memberCreator": {"username": "martha"}}
+	]
+}`
+
+func TestDecodeTrelloExport(t *testing.T) {
+	doc, source, err := decodeBoardImport([]byte(trelloFixture), defaultWorkflow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "trello" {
+		t.Fatalf("expected a Trello document, got %q", source)
+	}
+	if len(doc.Columns) != 2 || doc.Columns[0].Title != "To Do" || doc.Columns[1].Title != "Done" {
+		t.Fatalf("expected open lists in position order, got %+v", doc.Columns)
+	}
+
+	var titles []string
+	for _, task := range doc.Tasks {
+		titles = append(titles, task.Title)
+	}
+	if !slices.Equal(titles, []string{"First", "Second", "Shipped"}) {
+		t.Fatalf("expected open cards in position order, got %v", titles)
+	}
+	second, shipped := doc.Tasks[1], doc.Tasks[2]
+	if shipped.Status != "done" || second.Status != defaultWorkflow.Initial {
+		t.Fatalf("expected list names to map onto workflow states, got %q and %q", second.Status, shipped.Status)
+	}
+	if second.AssignedTo == nil || *second.AssignedTo != "martha" {
+		t.Fatalf("expected the first member to be the assignee, got %v", second.AssignedTo)
+	}
+	if len(second.Checklist) != 2 || second.Checklist[0].Title != "a" || !second.Checklist[1].Done {
+		t.Fatalf("expected checklist items in position order, got %+v", second.Checklist)
+	}
+	if len(second.Comments) != 2 || second.Comments[0].Body != "older" {
+		t.Fatalf("expected comments oldest first, got %+v", second.Comments)
+	}
+	if second.CreatedAt == nil || second.CreatedAt.Year() != 2020 {
+		t.Fatalf("expected the creation time from the card id, got %v", second.CreatedAt)
+	}
+	if doc.Labels[0].Color != "#eb5a46" || doc.Labels[1].Name != "green" {
+		t.Fatalf("expected Trello colours to be mapped, got %+v", doc.Labels)
+	}
+}
+
+func TestDecodeBoardImportRejectsUnknownDocuments(t *testing.T) {
+	for _, body := range []string{`[]`, `{"name": "x"}`, `{"format": "other"}`, `{"format": "hq-board", "version": 99}`} {
+		if _, _, err := decodeBoardImport([]byte(body), defaultWorkflow); err == nil {
+			t.Errorf("expected %s to be rejected", body)
+		}
+	}
+}
+
+func TestNormalizeRepairsReferences(t *testing.T) {
+	parentB, parentA := "b", "a"
+	doc := &boardExport{
+		Columns: []exportedColumn{{Key: "c1", Title: " Backlog "}},
+		Labels: []exportedLabel{
+			{Key: "x", Name: "Bug", Color: "#FF0000"},
+			{Key: "y", Name: "bug", Color: "nope"},
+		},
+		Tasks: []exportedTask{
+			{Key: "a", Column: "c1", Title: "A", Parent: &parentB, Labels: []string{"x", "y", "z"}, BlockedBy: []string{"b"}},
+			{Key: "b", Column: "missing", Title: "B", Parent: &parentA, Status: "shipped", BlockedBy: []string{"a", "ghost"}},
+			{Key: "c", Column: "c1", Title: "  "},
+		},
+	}
+	warnings, err := doc.normalize(defaultWorkflow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Labels) != 1 || doc.Labels[0].Color != "#ff0000" {
+		t.Fatalf("expected same-named labels to be merged, got %+v", doc.Labels)
+	}
+	if len(doc.Tasks) != 2 {
+		t.Fatalf("expected the untitled task to be skipped, got %d tasks", len(doc.Tasks))
+	}
+	a, b := doc.Tasks[0], doc.Tasks[1]
+	if !slices.Equal(a.Labels, []string{"x"}) {
+		t.Fatalf("expected labels to resolve to the merged label, got %v", a.Labels)
+	}
+	if b.Column != "c1" || b.Status != defaultWorkflow.Initial {
+		t.Fatalf("expected unknown column and status to be repaired, got %q %q", b.Column, b.Status)
+	}
+	if a.Parent == nil || b.Parent != nil {
+		t.Fatal("expected the parent link closing a loop to be dropped")
+	}
+	if len(a.BlockedBy) != 1 || len(b.BlockedBy) != 0 {
+		t.Fatalf("expected the cyclic and dangling dependencies to be dropped, got %v %v", a.BlockedBy, b.BlockedBy)
+	}
+	if len(warnings) == 0 {
+		t.Fatal("expected the repairs to be reported")
+	}
+}
+
+func TestNormalizeRejectsDuplicateKeys(t *testing.T) {
+	doc := &boardExport{
+		Columns: []exportedColumn{{Key: "c1", Title: "A"}},
+		Tasks:   []exportedTask{{Key: "t", Column: "c1", Title: "x"}, {Key: "t", Column: "c1", Title: "y"}},
+	}
+	if _, err := doc.normalize(defaultWorkflow); err == nil {
+		t.Fatal("expected duplicate task keys to be rejected")
+	}
+}
+
+func TestImportBoardDryRun(t *testing.T) {
+	s := &server{}
+	r := httptest.NewRequest(http.MethodPost, "/api/boards/import?dry_run=true&name=Migrated", strings.NewReader(trelloFixture))
+	w := httptest.NewRecorder()
+
+	s.importBoard(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+	var got importSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.DryRun || got.Source != "trello" || got.Name != "Migrated" {
+		t.Fatalf("unexpected summary %+v", got)
+	}
+	if got.Columns != 2 || got.Labels != 2 || got.Tasks != 3 || got.ChecklistItems != 2 || got.Comments != 2 {
+		t.Fatalf("unexpected counts %+v", got)
+	}
+}
@@ -0,0 +1,761 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// boardSchema mirrors the boards/columns tables from schema.sql under the
+// api_ prefix and links api_tasks to its column.
+var boardSchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_boards (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		name TEXT NOT NULL,
+		owner_id TEXT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE TABLE IF NOT EXISTS public.api_columns (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		board_id UUID NOT NULL REFERENCES public.api_boards (id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		"order" INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_columns_board_order_idx ON public.api_columns (board_id, "order")`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'api_tasks_column_id_fkey') THEN
+			ALTER TABLE public.api_tasks
+				ADD CONSTRAINT api_tasks_column_id_fkey
+				FOREIGN KEY (column_id) REFERENCES public.api_columns (id) ON DELETE CASCADE NOT VALID;
+		END IF;
+	END $$`,
+	`CREATE INDEX IF NOT EXISTS api_tasks_column_order_idx ON public.api_tasks (column_id, "order")`,
+}
+
+// defaultColumns seeds a new board when the caller does not name its columns;
+// it matches the columns the TrelloBoard component starts with.
+var defaultColumns = []string{"To Do", "In Progress", "Done"}
+
+type board struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	OwnerID   *string   `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type column struct {
+	ID               string      `json:"id"`
+	BoardID          string      `json:"board_id"`
+	Title            string      `json:"title"`
+	Order            int         `json:"order"`
+	WIPLimit         *int        `json:"wip_limit"`
+	WIPLimitPerAgent *int        `json:"wip_limit_per_agent"`
+	Load             *columnLoad `json:"load,omitempty"`
+	ArchivedAt       *time.Time  `json:"archived_at"`
+	CreatedAt        time.Time   `json:"created_at"`
+}
+
+// boardView is the nested representation rendered by the TrelloBoard component.
+type boardView struct {
+	board
+	Columns []columnView `json:"columns"`
+}
+
+type columnView struct {
+	column
+	Tasks []task `json:"tasks"`
+}
+
+const columnColumns = `id::text, board_id::text, title, "order", wip_limit, wip_limit_per_agent, archived_at, created_at`
+
+func scanBoard(row rowScanner) (board, error) {
+	var b board
+	err := row.Scan(&b.ID, &b.Name, &b.OwnerID, &b.CreatedAt)
+	return b, err
+}
+
+func scanColumn(row rowScanner) (column, error) {
+	var c column
+	err := row.Scan(&c.ID, &c.BoardID, &c.Title, &c.Order, &c.WIPLimit, &c.WIPLimitPerAgent, &c.ArchivedAt, &c.CreatedAt)
+	return c, err
+}
+
+func (s *server) boards(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listBoards(w, r)
+	case http.MethodPost:
+		s.createBoard(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) boardItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getBoard(w, r)
+	case http.MethodPatch:
+		s.updateBoard(w, r)
+	case http.MethodDelete:
+		s.deleteBoard(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) boardColumns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listColumns(w, r)
+	case http.MethodPost:
+		s.createColumn(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) columnItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		s.updateColumn(w, r)
+	case http.MethodDelete:
+		s.deleteColumn(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) columnTasks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listColumnTasks(w, r)
+	case http.MethodPost:
+		s.createTask(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) listBoards(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id::text, name, owner_id, created_at
+		FROM public.api_boards
+		ORDER BY created_at ASC`)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]board, 0)
+	for rows.Next() {
+		b, err := scanBoard(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, b)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) createBoard(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	var in struct {
+		Name    string    `json:"name"`
+		OwnerID *string   `json:"owner_id"`
+		Columns *[]string `json:"columns"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name is required"})
+		return
+	}
+	titles := defaultColumns
+	if in.Columns != nil {
+		titles = make([]string, 0, len(*in.Columns))
+		for _, title := range *in.Columns {
+			title = strings.TrimSpace(title)
+			if title == "" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "column titles must not be empty"})
+				return
+			}
+			titles = append(titles, title)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	b, err := scanBoard(tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_boards (name, owner_id)
+		VALUES ($1, NULLIF($2, ''))
+		RETURNING id::text, name, owner_id, created_at`, in.Name, trimmedOrEmpty(in.OwnerID),
+	))
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	out := boardView{board: b, Columns: make([]columnView, 0, len(titles))}
+	for i, title := range titles {
+		c, err := scanColumn(tx.QueryRowContext(ctx, `
+			INSERT INTO public.api_columns (board_id, title, "order")
+			VALUES ($1, $2, $3)
+			RETURNING `+columnColumns, b.ID, title, i,
+		))
+		if err != nil {
+			writeStoreError(w, err, "board not found")
+			return
+		}
+		out.Columns = append(out.Columns, columnView{column: c, Tasks: make([]task, 0)})
+	}
+
+	if err := tx.Commit(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (s *server) getBoard(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Read the board, its columns and their tasks from one snapshot so the
+	// nested view never mixes states from concurrent moves.
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	out, err := loadBoardView(ctx, tx, s.taskWorkflow(), id)
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func loadBoardView(ctx context.Context, tx *sql.Tx, wf *workflow, id string) (boardView, error) {
+	b, err := scanBoard(tx.QueryRowContext(ctx, `
+		SELECT id::text, name, owner_id, created_at
+		FROM public.api_boards
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		return boardView{}, err
+	}
+	out := boardView{board: b, Columns: make([]columnView, 0)}
+
+	columnRows, err := tx.QueryContext(ctx, `
+		SELECT `+columnColumns+`
+		FROM public.api_columns
+		WHERE board_id = $1 AND archived_at IS NULL
+		ORDER BY "order" ASC, created_at ASC`, id)
+	if err != nil {
+		return boardView{}, err
+	}
+	defer columnRows.Close()
+
+	index := make(map[string]int)
+	for columnRows.Next() {
+		c, err := scanColumn(columnRows)
+		if err != nil {
+			return boardView{}, err
+		}
+		index[c.ID] = len(out.Columns)
+		out.Columns = append(out.Columns, columnView{column: c, Tasks: make([]task, 0)})
+	}
+	if err := columnRows.Err(); err != nil {
+		return boardView{}, err
+	}
+
+	taskRows, err := tx.QueryContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE column_id IN (SELECT id FROM public.api_columns WHERE board_id = $1) AND archived_at IS NULL
+		ORDER BY rank ASC NULLS LAST, "order" ASC, created_at ASC`, id)
+	if err != nil {
+		return boardView{}, err
+	}
+	defer taskRows.Close()
+
+	for taskRows.Next() {
+		t, err := scanTask(taskRows)
+		if err != nil {
+			return boardView{}, err
+		}
+		if t.ColumnID == nil {
+			continue
+		}
+		if i, ok := index[*t.ColumnID]; ok {
+			out.Columns[i].Tasks = append(out.Columns[i].Tasks, t)
+		}
+	}
+	if err := taskRows.Err(); err != nil {
+		return boardView{}, err
+	}
+	taskRows.Close()
+
+	var tasks []*task
+	for i := range out.Columns {
+		tasks = append(tasks, taskPointers(out.Columns[i].Tasks)...)
+	}
+	if err := attachProgress(ctx, tx, wf, tasks); err != nil {
+		return boardView{}, err
+	}
+	if err := attachTimeSpent(ctx, tx, tasks); err != nil {
+		return boardView{}, err
+	}
+	columns := make([]*column, len(out.Columns))
+	for i := range out.Columns {
+		columns[i] = &out.Columns[i].column
+	}
+	if err := attachColumnLoad(ctx, tx, columns); err != nil {
+		return boardView{}, err
+	}
+	return out, nil
+}
+
+func (s *server) updateBoard(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	var in struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	if in.Name == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "name is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	out, err := scanBoard(s.db.QueryRowContext(ctx, `
+		UPDATE public.api_boards
+		SET name = $1
+		WHERE id = $2
+		RETURNING id::text, name, owner_id, created_at`, in.Name, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// deleteBoard deletes a board with its columns and tasks; each task is
+// audited as deleted.
+func (s *server) deleteBoard(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the board and its columns keeps tasks from being added while
+	// the existing ones are deleted and audited.
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM public.api_boards WHERE id = $1 FOR UPDATE`, id); err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM public.api_columns WHERE board_id = $1 FOR UPDATE`, id); err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+	if _, err := deleteTasks(ctx, tx, actorFromRequest(r), `column_id IN (
+		SELECT id FROM public.api_columns WHERE board_id = $1)`, id); err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+	out, err := scanBoard(tx.QueryRowContext(ctx, `
+		DELETE FROM public.api_boards
+		WHERE id = $1
+		RETURNING id::text, name, owner_id, created_at`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) listColumns(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+columnColumns+`
+		FROM public.api_columns
+		WHERE board_id = $1 AND archived_at IS NULL
+		ORDER BY "order" ASC, created_at ASC`, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]column, 0)
+	for rows.Next() {
+		c, err := scanColumn(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := attachColumnLoad(ctx, s.db, columnPointers(items)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) createColumn(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	var in struct {
+		Title            string `json:"title"`
+		Order            *int   `json:"order"`
+		WIPLimit         *int   `json:"wip_limit"`
+		WIPLimitPerAgent *int   `json:"wip_limit_per_agent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Title == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "title is required"})
+		return
+	}
+	limit, err := parseWIPLimit("wip_limit", in.WIPLimit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	perAgent, err := parseWIPLimit("wip_limit_per_agent", in.WIPLimitPerAgent)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	// Without an explicit order the column is appended after the last one.
+	out, err := scanColumn(s.db.QueryRowContext(ctx, `
+		INSERT INTO public.api_columns (board_id, title, "order", wip_limit, wip_limit_per_agent)
+		VALUES ($1, $2, COALESCE($3, (SELECT COALESCE(MAX("order") + 1, 0) FROM public.api_columns WHERE board_id = $1)), $4, $5)
+		RETURNING `+columnColumns, id, in.Title, in.Order, limit, perAgent,
+	))
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (s *server) updateColumn(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "column not found"})
+		return
+	}
+
+	var in struct {
+		Title            *string `json:"title"`
+		Order            *int    `json:"order"`
+		WIPLimit         *int    `json:"wip_limit"`
+		WIPLimitPerAgent *int    `json:"wip_limit_per_agent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if in.Title != nil {
+		title := strings.TrimSpace(*in.Title)
+		if title == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "title must not be empty"})
+			return
+		}
+		set("title", title)
+	}
+	if in.Order != nil {
+		set(`"order"`, *in.Order)
+	}
+	// A limit of 0 removes it. Lowering a limit below the current load is
+	// allowed; the column is then reported as over its limit.
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{{"wip_limit", in.WIPLimit}, {"wip_limit_per_agent", in.WIPLimitPerAgent}} {
+		if field.value == nil {
+			continue
+		}
+		limit, err := parseWIPLimit(field.name, field.value)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		set(field.name, limit)
+	}
+	if len(sets) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no fields to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	args = append(args, id)
+	out, err := scanColumn(s.db.QueryRowContext(ctx, `
+		UPDATE public.api_columns
+		SET `+strings.Join(sets, ", ")+`
+		WHERE id = $`+fmt.Sprint(len(args))+`
+		RETURNING `+columnColumns, args...,
+	))
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if err := attachColumnLoad(ctx, s.db, []*column{&out}); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// deleteColumn archives a column and its tasks; ?permanent=true deletes the
+// column and everything in it for good, auditing each task.
+func (s *server) deleteColumn(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "column not found"})
+		return
+	}
+	permanent, err := permanentParam(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if !permanent {
+		s.archiveColumn(w, r, id)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := lockColumn(ctx, tx, id); err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if _, err := deleteTasks(ctx, tx, actorFromRequest(r), `column_id = $1`, id); err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	out, err := scanColumn(tx.QueryRowContext(ctx, `
+		DELETE FROM public.api_columns
+		WHERE id = $1
+		RETURNING `+columnColumns, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "column not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) listColumnTasks(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "column not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE column_id = $1 AND archived_at IS NULL
+		ORDER BY rank ASC NULLS LAST, "order" ASC, created_at ASC`, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]task, 0)
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, t)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := attachProgress(ctx, s.db, s.taskWorkflow(), taskPointers(items)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := attachTimeSpent(ctx, s.db, taskPointers(items)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBoardsRequireDatabase(t *testing.T) {
+	s := &server{}
+	r := httptest.NewRequest(http.MethodGet, "/api/boards", nil)
+	w := httptest.NewRecorder()
+
+	s.boards(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+}
+
+func TestBoardViewJSONShape(t *testing.T) {
+	columnID := "c1"
+	view := boardView{
+		board: board{ID: "b1", Name: "HQ"},
+		Columns: []columnView{{
+			column: column{ID: columnID, BoardID: "b1", Title: "To Do"},
+			Tasks:  []task{{ID: "t1", ColumnID: &columnID, Title: "Ship it"}},
+		}},
+	}
+
+	data, err := json.Marshal(view)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+
+	var payload struct {
+		ID      string `json:"id"`
+		Columns []struct {
+			ID    string `json:"id"`
+			Title string `json:"title"`
+			Tasks []struct {
+				ID       string `json:"id"`
+				ColumnID string `json:"column_id"`
+			} `json:"tasks"`
+		} `json:"columns"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if payload.ID != "b1" || len(payload.Columns) != 1 || payload.Columns[0].Title != "To Do" {
+		t.Fatalf("unexpected board payload: %s", data)
+	}
+	if len(payload.Columns[0].Tasks) != 1 || payload.Columns[0].Tasks[0].ColumnID != columnID {
+		t.Fatalf("expected nested task in column, got %s", data)
+	}
+}
+
+func TestDeleteBoardAuditsTasks(t *testing.T) {
+	f, db := newFakeDB(t)
+	f.answer("DELETE FROM public.api_tasks", taskRowColumns,
+		taskRow(task{ID: "t1", Title: "Doomed", Status: "todo"}))
+	f.answer("DELETE FROM public.api_boards", []string{"id", "name", "owner_id", "created_at"},
+		[]driver.Value{"00000000-0000-0000-0000-000000000001", "Board", nil, time.Now()})
+
+	s := &server{db: db}
+	r := httptest.NewRequest(http.MethodDelete, "/api/boards/00000000-0000-0000-0000-000000000001", nil)
+	r.SetPathValue("id", "00000000-0000-0000-0000-000000000001")
+	r.Header.Set("X-User-ID", "martha")
+	w := httptest.NewRecorder()
+	s.deleteBoard(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body)
+	}
+	history := f.find("INSERT INTO public.api_task_history")
+	if len(history) != 1 || history[0].Args[0] != "t1" || history[0].Args[1] != "delete" || history[0].Args[3] != "martha" {
+		t.Fatalf("expected the task's deletion to be recorded, got %+v", history)
+	}
+	board := f.find("DELETE FROM public.api_boards")
+	if len(board) != 1 || board[0].Tx != history[0].Tx || board[0].Tx == 0 {
+		t.Fatalf("expected the board to be deleted in the audited transaction, got %+v", board)
+	}
+	if logs := f.find("INSERT INTO public.api_logs"); len(logs) != 1 {
+		t.Errorf("expected an activity row for the task, got %d", len(logs))
+	}
+}
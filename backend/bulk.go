@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBulkOperations bounds a single POST /api/tasks/bulk request so that one
+// call cannot hold row locks on the whole board for long.
+const maxBulkOperations = 200
+
+// bulkOperation is one item of a bulk request. Op selects which of the other
+// fields apply:
+//
+//	move     column_id and optionally before_id, after_id or index
+//	status   status
+//	assign   assigned_to (empty unassigns)
+//	label    label_id
+//	unlabel  label_id
+//	delete   nothing else; the task is archived
+//
+// Version, when set, must match the task's current version like If-Match.
+// OverrideWIP lets a move or assignment exceed a column's WIP limit.
+type bulkOperation struct {
+	Op          string  `json:"op"`
+	TaskID      string  `json:"task_id"`
+	Version     *int    `json:"version"`
+	ColumnID    string  `json:"column_id"`
+	BeforeID    string  `json:"before_id"`
+	AfterID     string  `json:"after_id"`
+	Index       *int    `json:"index"`
+	Status      string  `json:"status"`
+	AssignedTo  *string `json:"assigned_to"`
+	LabelID     string  `json:"label_id"`
+	OverrideWIP bool    `json:"override_wip"`
+}
+
+// bulkResult reports the outcome of one operation, in request order.
+type bulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	TaskID string `json:"task_id"`
+	OK     bool   `json:"ok"`
+	Status int    `json:"status"`
+	Task   *task  `json:"task,omitempty"`
+	Error  any    `json:"error,omitempty"`
+}
+
+// validate applies the request-level checks the single-task endpoints make
+// before touching the database.
+func (op *bulkOperation) validate(wf *workflow) error {
+	op.Op = strings.TrimSpace(op.Op)
+	op.TaskID = strings.TrimSpace(op.TaskID)
+	if !isUUID(op.TaskID) {
+		return errors.New("invalid task_id")
+	}
+	switch op.Op {
+	case "move":
+		op.ColumnID = strings.TrimSpace(op.ColumnID)
+		return validateMove(op.TaskID, op.ColumnID, op.placement())
+	case "status":
+		op.Status = strings.TrimSpace(op.Status)
+		if op.Status == "" {
+			return errors.New("status must not be empty")
+		}
+		return wf.checkState(op.Status)
+	case "assign":
+		if op.AssignedTo == nil {
+			return errors.New("assigned_to is required")
+		}
+	case "label", "unlabel":
+		op.LabelID = strings.TrimSpace(op.LabelID)
+		if !isUUID(op.LabelID) {
+			return errors.New("invalid label_id")
+		}
+	case "delete":
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	return nil
+}
+
+func (op *bulkOperation) placement() placement {
+	return placement{BeforeID: strings.TrimSpace(op.BeforeID), AfterID: strings.TrimSpace(op.AfterID), Index: op.Index}
+}
+
+// bulkTasks applies a list of operations in one transaction. By default each
+// operation runs under its own savepoint, so a failing item is rolled back on
+// its own and reported while the rest commit. With "atomic": true the first
+// failure rolls back the whole request.
+func (s *server) bulkTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	var in struct {
+		Atomic      bool            `json:"atomic"`
+		OverrideWIP bool            `json:"override_wip"`
+		Operations  []bulkOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	if len(in.Operations) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "operations is required"})
+		return
+	}
+	if len(in.Operations) > maxBulkOperations {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("at most %d operations per request", maxBulkOperations)})
+		return
+	}
+
+	wf := s.taskWorkflow()
+	results := make([]bulkResult, len(in.Operations))
+	invalid := false
+	for i := range in.Operations {
+		op := &in.Operations[i]
+		op.OverrideWIP = op.OverrideWIP || in.OverrideWIP
+		results[i] = bulkResult{Index: i, Op: op.Op, TaskID: op.TaskID}
+		if err := op.validate(wf); err != nil {
+			invalid = true
+			results[i].Status, results[i].Error = validationError(err)
+		}
+	}
+	if invalid && in.Atomic {
+		writeJSON(w, http.StatusBadRequest, map[string]any{"error": "invalid operations, nothing was applied", "results": results})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	by := actorFromRequest(r)
+	for i, op := range in.Operations {
+		if results[i].Error != nil {
+			continue
+		}
+		if !in.Atomic {
+			if _, err := tx.ExecContext(ctx, `SAVEPOINT bulk_operation`); err != nil {
+				writeStoreError(w, err, "task not found")
+				return
+			}
+		}
+		out, err := s.applyBulkOperation(ctx, tx, by, op)
+		if err != nil {
+			results[i].Status, results[i].Error = storeErrorResponse(err, "task not found")
+			if in.Atomic {
+				writeJSON(w, results[i].Status, map[string]any{
+					"error":   fmt.Sprintf("operation %d failed, nothing was applied", i),
+					"results": results[:i+1],
+				})
+				return
+			}
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_operation`); err != nil {
+				writeStoreError(w, err, "task not found")
+				return
+			}
+			continue
+		}
+		if !in.Atomic {
+			if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT bulk_operation`); err != nil {
+				writeStoreError(w, err, "task not found")
+				return
+			}
+		}
+		results[i].OK, results[i].Status, results[i].Task = true, http.StatusOK, out
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	succeeded := 0
+	for _, res := range results {
+		if res.OK {
+			succeeded++
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
+
+// validationError renders a request-level validation failure like the
+// single-task endpoints do: workflow violations keep their 422 payload and
+// everything else is a 400.
+func validationError(err error) (int, any) {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.status, statusErr.body
+	}
+	return http.StatusBadRequest, map[string]string{"error": err.Error()}
+}
+
+// applyBulkOperation runs one validated operation inside tx with the same
+// locking, workflow checks and auditing as the single-task endpoints. It
+// returns the task after the change.
+func (s *server) applyBulkOperation(ctx context.Context, tx *sql.Tx, by actor, op bulkOperation) (*task, error) {
+	target := ""
+	if op.Op == "move" {
+		target = op.ColumnID
+	}
+	before, err := lockTask(ctx, tx, op.TaskID, target)
+	if err != nil {
+		return nil, err
+	}
+	if op.Version != nil && *op.Version != before.Version {
+		return nil, &statusError{status: http.StatusPreconditionFailed, body: map[string]any{
+			"error":   "task has been modified since it was read",
+			"current": before,
+		}}
+	}
+
+	switch op.Op {
+	case "move":
+		err = placeTask(ctx, tx, before, op.ColumnID, op.placement())
+	case "status":
+		wf := s.taskWorkflow()
+		if err = wf.checkTransition(before.Status, op.Status); err == nil {
+			err = checkBlockers(ctx, tx, wf, op.TaskID, op.Status)
+		}
+		if err == nil {
+			_, err = tx.ExecContext(ctx, `
+				UPDATE public.api_tasks
+				SET status = $1, overdue_at = CASE WHEN $3 THEN NULL ELSE overdue_at END,
+					version = version + 1, updated_at = timezone('utc'::text, now())
+				WHERE id = $2`, op.Status, op.TaskID, wf.isFinal(op.Status),
+			)
+		}
+	case "assign":
+		_, err = tx.ExecContext(ctx, `
+			UPDATE public.api_tasks
+			SET assigned_to = NULLIF($1, ''), version = version + 1, updated_at = timezone('utc'::text, now())
+			WHERE id = $2`, trimmedOrEmpty(op.AssignedTo), op.TaskID,
+		)
+	case "label", "unlabel":
+		var changed bool
+		if op.Op == "label" {
+			changed, err = attachLabel(ctx, tx, before, op.LabelID)
+		} else {
+			changed, err = detachLabel(ctx, tx, op.TaskID, op.LabelID)
+		}
+		if err == nil && changed {
+			err = touchTask(ctx, tx, op.TaskID)
+		}
+	case "delete":
+		after, err := setTaskArchived(ctx, tx, by, before, true)
+		if err == nil && after.ColumnID != nil {
+			err = renumberColumn(ctx, tx, *after.ColumnID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &after, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := scanTask(tx.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1`, op.TaskID,
+	))
+	if err != nil {
+		return nil, err
+	}
+	if err := enforceWIP(ctx, tx, by, &before, after, op.OverrideWIP); err != nil {
+		return nil, err
+	}
+	if err := auditTask(ctx, tx, by, &before, &after); err != nil {
+		return nil, err
+	}
+	return &after, nil
+}
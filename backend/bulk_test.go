@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBulkOperationValidate(t *testing.T) {
+	id := "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11"
+	col := "0b7d1c52-3f7e-4b8e-9a52-6f1f1c0d9e21"
+	empty := ""
+
+	for _, op := range []bulkOperation{
+		{Op: "move", TaskID: id, ColumnID: col},
+		{Op: "status", TaskID: id, Status: " done "},
+		{Op: "assign", TaskID: id, AssignedTo: &empty},
+		{Op: "label", TaskID: id, LabelID: col},
+		{Op: "delete", TaskID: id},
+	} {
+		if err := op.validate(defaultWorkflow); err != nil {
+			t.Fatalf("expected %+v to be valid, got %v", op, err)
+		}
+	}
+
+	for _, op := range []bulkOperation{
+		{Op: "archive", TaskID: id},
+		{Op: "delete", TaskID: "t1"},
+		{Op: "move", TaskID: id},
+		{Op: "move", TaskID: id, ColumnID: col, BeforeID: id},
+		{Op: "status", TaskID: id},
+		{Op: "assign", TaskID: id},
+		{Op: "unlabel", TaskID: id, LabelID: "bug"},
+	} {
+		if err := op.validate(defaultWorkflow); err == nil {
+			t.Fatalf("expected %+v to be rejected", op)
+		}
+	}
+}
+
+func TestBulkValidationErrorKeepsWorkflowPayload(t *testing.T) {
+	op := bulkOperation{Op: "status", TaskID: "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11", Status: "shipped"}
+	status, body := validationError(op.validate(defaultWorkflow))
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for an unknown status, got %d", status)
+	}
+	if _, ok := body.(map[string]any)["states"]; !ok {
+		t.Fatalf("expected the workflow states in the payload, got %#v", body)
+	}
+
+	if status, _ := validationError(errors.New("invalid task_id")); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a plain validation error, got %d", status)
+	}
+}
@@ -0,0 +1,593 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// checklistSchema adds parent/child links between tasks and ordered,
+// checkable checklist items. Deleting a parent keeps its subtasks as
+// top-level tasks.
+var checklistSchema = []string{
+	`ALTER TABLE public.api_tasks ADD COLUMN IF NOT EXISTS parent_id UUID`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'api_tasks_parent_id_fkey') THEN
+			ALTER TABLE public.api_tasks
+				ADD CONSTRAINT api_tasks_parent_id_fkey
+				FOREIGN KEY (parent_id) REFERENCES public.api_tasks (id) ON DELETE SET NULL NOT VALID;
+		END IF;
+	END $$`,
+	`CREATE INDEX IF NOT EXISTS api_tasks_parent_idx ON public.api_tasks (parent_id) WHERE parent_id IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS public.api_checklist_items (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		task_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		done BOOLEAN NOT NULL DEFAULT false,
+		position INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_checklist_items_task_idx ON public.api_checklist_items (task_id, position)`,
+}
+
+const maxChecklistTitleLength = 500
+
+type checklistItem struct {
+	ID        string    `json:"id"`
+	TaskID    string    `json:"task_id"`
+	Title     string    `json:"title"`
+	Done      bool      `json:"done"`
+	Position  int       `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const checklistColumns = `id::text, task_id::text, title, done, position, created_at, updated_at`
+
+func scanChecklistItem(row rowScanner) (checklistItem, error) {
+	var c checklistItem
+	err := row.Scan(&c.ID, &c.TaskID, &c.Title, &c.Done, &c.Position, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+type progressCount struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// taskProgress summarises a card's checklist and subtasks so the board can
+// draw progress bars. Done and Total add both up; Percent is rounded down.
+type taskProgress struct {
+	Done      int           `json:"done"`
+	Total     int           `json:"total"`
+	Percent   int           `json:"percent"`
+	Checklist progressCount `json:"checklist"`
+	Subtasks  progressCount `json:"subtasks"`
+}
+
+func newTaskProgress(checklist, subtasks progressCount) *taskProgress {
+	p := &taskProgress{
+		Done:      checklist.Done + subtasks.Done,
+		Total:     checklist.Total + subtasks.Total,
+		Checklist: checklist,
+		Subtasks:  subtasks,
+	}
+	if p.Total > 0 {
+		p.Percent = p.Done * 100 / p.Total
+	}
+	return p
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// attachProgress fills in Progress for tasks returned by list endpoints with
+// a single query. Subtasks count as done once they reach a final workflow
+// state; archived subtasks are left out.
+func attachProgress(ctx context.Context, q queryer, wf *workflow, tasks []*task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tasks))
+	byID := make(map[string]*task, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+		byID[t.ID] = t
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT t.id::text,
+			(SELECT count(*) FILTER (WHERE c.done) FROM public.api_checklist_items c WHERE c.task_id = t.id),
+			(SELECT count(*) FROM public.api_checklist_items c WHERE c.task_id = t.id),
+			(SELECT count(*) FILTER (WHERE s.status = ANY($2)) FROM public.api_tasks s WHERE s.parent_id = t.id AND s.archived_at IS NULL),
+			(SELECT count(*) FROM public.api_tasks s WHERE s.parent_id = t.id AND s.archived_at IS NULL)
+		FROM unnest($1::uuid[]) AS t(id)`, ids, wf.finalStates())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var checklist, subtasks progressCount
+		if err := rows.Scan(&id, &checklist.Done, &checklist.Total, &subtasks.Done, &subtasks.Total); err != nil {
+			return err
+		}
+		if t, ok := byID[id]; ok {
+			t.Progress = newTaskProgress(checklist, subtasks)
+		}
+	}
+	return rows.Err()
+}
+
+func taskPointers(items []task) []*task {
+	out := make([]*task, len(items))
+	for i := range items {
+		out[i] = &items[i]
+	}
+	return out
+}
+
+// checkParent validates a new parent for taskID: it must exist and must not
+// be the task itself or one of its descendants. An empty taskID is a task
+// that is being created.
+func checkParent(ctx context.Context, tx *sql.Tx, taskID, parentID string) error {
+	if parentID == taskID {
+		return newStatusError(http.StatusUnprocessableEntity, "a task cannot be its own parent")
+	}
+	// Serialise re-parenting so two concurrent changes cannot form a loop.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('api_tasks.parent_id'))`); err != nil {
+		return err
+	}
+	var exists, loops bool
+	err := tx.QueryRowContext(ctx, `
+		WITH RECURSIVE ancestors (id, parent_id) AS (
+			SELECT id, parent_id FROM public.api_tasks WHERE id = $1
+			UNION
+			SELECT t.id, t.parent_id
+			FROM public.api_tasks t
+			JOIN ancestors a ON t.id = a.parent_id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = $1),
+			EXISTS (SELECT 1 FROM ancestors WHERE id::text = $2)`, parentID, taskID,
+	).Scan(&exists, &loops)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newStatusError(http.StatusNotFound, "parent task not found")
+	}
+	if loops {
+		return newStatusError(http.StatusUnprocessableEntity, "a task cannot be nested under its own subtask")
+	}
+	return nil
+}
+
+// parentFilter handles ?parent= on GET /api/tasks: a task id lists its
+// subtasks and "none" lists top-level tasks only.
+func parentFilter(where *whereBuilder, q url.Values) error {
+	v := strings.TrimSpace(q.Get("parent"))
+	if v == "" {
+		v = strings.TrimSpace(q.Get("parent_id"))
+	}
+	switch {
+	case v == "":
+	case v == "none":
+		where.add("parent_id IS NULL")
+	case isUUID(v):
+		where.add("parent_id = " + where.arg(v) + "::uuid")
+	default:
+		return fmt.Errorf("invalid parent %q", v)
+	}
+	return nil
+}
+
+// moveID returns ids with id moved to index, clamped to the list bounds.
+func moveID(ids []string, id string, index int) []string {
+	out := make([]string, 0, len(ids))
+	for _, v := range ids {
+		if v != id {
+			out = append(out, v)
+		}
+	}
+	index = max(0, min(index, len(out)))
+	out = append(out, "")
+	copy(out[index+1:], out[index:])
+	out[index] = id
+	return out
+}
+
+func parseChecklistTitle(v string) (string, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", errors.New("title is required")
+	}
+	if len([]rune(v)) > maxChecklistTitleLength {
+		return "", fmt.Errorf("title must be at most %d characters", maxChecklistTitleLength)
+	}
+	return v, nil
+}
+
+func (s *server) taskChecklist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listChecklist(w, r)
+	case http.MethodPost:
+		s.createChecklistItem(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) taskChecklistItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		s.updateChecklistItem(w, r)
+	case http.MethodDelete:
+		s.deleteChecklistItem(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) listChecklist(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+checklistColumns+`
+		FROM public.api_checklist_items
+		WHERE task_id = $1
+		ORDER BY position ASC, created_at ASC`, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]checklistItem, 0)
+	for rows.Next() {
+		c, err := scanChecklistItem(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// lockTaskRow locks the task owning checklist items or time entries so
+// concurrent edits to them apply one at a time, and returns its title for the
+// log. Archived tasks are read-only.
+func lockTaskRow(ctx context.Context, tx *sql.Tx, taskID string) (string, error) {
+	var title string
+	var archived bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT title, archived_at IS NOT NULL
+		FROM public.api_tasks
+		WHERE id = $1
+		FOR UPDATE`, taskID,
+	).Scan(&title, &archived)
+	if err == nil && archived {
+		err = newStatusError(http.StatusConflict, "task is archived, restore it first")
+	}
+	return title, err
+}
+
+// checklistFields returns the audited fields of a checklist item.
+func checklistFields(c *checklistItem) map[string]any {
+	if c == nil {
+		return nil
+	}
+	return map[string]any{
+		"checklist_item_id": c.ID,
+		"title":             c.Title,
+		"done":              c.Done,
+		"position":          c.Position,
+	}
+}
+
+// auditChecklistChange records a change to one checklist item as a mutation
+// of its task: the task gets a new version, a history entry and an activity
+// log row, inside the caller's transaction. A nil before is an added item and
+// a nil after a removed one.
+func auditChecklistChange(ctx context.Context, tx *sql.Tx, by actor, taskID, action, message string, before, after *checklistItem) error {
+	if err := touchTask(ctx, tx, taskID); err != nil {
+		return err
+	}
+	change := taskChange{Action: action, OldValues: checklistFields(before), NewValues: checklistFields(after)}
+	if err := insertHistoryEntry(ctx, tx, by, taskID, change); err != nil {
+		return err
+	}
+	item := after
+	if item == nil {
+		item = before
+	}
+	return insertActivityLog(ctx, tx, by, taskID, message, map[string]any{
+		"actions":           []string{action},
+		"checklist_item_id": item.ID,
+	})
+}
+
+// placeChecklistItem moves itemID to index within its task's checklist and
+// rewrites positions as 0..n-1.
+func placeChecklistItem(ctx context.Context, tx *sql.Tx, taskID, itemID string, index int) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id::text
+		FROM public.api_checklist_items
+		WHERE task_id = $1
+		ORDER BY position ASC, created_at ASC`, taskID)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE public.api_checklist_items c
+		SET position = o.ordinality - 1
+		FROM unnest($1::uuid[]) WITH ORDINALITY AS o(id, ordinality)
+		WHERE c.id = o.id AND c.position <> o.ordinality - 1`, moveID(ids, itemID, index))
+	return err
+}
+
+func (s *server) createChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	var in struct {
+		Title    string `json:"title"`
+		Done     bool   `json:"done"`
+		Position *int   `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	title, err := parseChecklistTitle(in.Title)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	taskTitle, err := lockTaskRow(ctx, tx, id)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	// New items are appended; an explicit position is applied afterwards.
+	out, err := scanChecklistItem(tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_checklist_items (task_id, title, done, position)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(position) + 1, 0) FROM public.api_checklist_items WHERE task_id = $1))
+		RETURNING `+checklistColumns, id, title, in.Done,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if in.Position != nil {
+		if err := placeChecklistItem(ctx, tx, id, out.ID, *in.Position); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		if out, err = scanChecklistItem(tx.QueryRowContext(ctx, `
+			SELECT `+checklistColumns+`
+			FROM public.api_checklist_items
+			WHERE id = $1`, out.ID,
+		)); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+	by := actorFromRequest(r)
+	message := fmt.Sprintf("%s added %q to the checklist of task %q", by.ID, title, taskTitle)
+	if err := auditChecklistChange(ctx, tx, by, id, "checklist_add", message, nil, &out); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (s *server) updateChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	taskID, itemID := r.PathValue("id"), r.PathValue("itemID")
+	if !isUUID(taskID) || !isUUID(itemID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "checklist item not found"})
+		return
+	}
+	var in struct {
+		Title    *string `json:"title"`
+		Done     *bool   `json:"done"`
+		Position *int    `json:"position"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	if in.Title == nil && in.Done == nil && in.Position == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no fields to update"})
+		return
+	}
+	if in.Title != nil {
+		title, err := parseChecklistTitle(*in.Title)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		in.Title = &title
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	taskTitle, err := lockTaskRow(ctx, tx, taskID)
+	if err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+	before, err := scanChecklistItem(tx.QueryRowContext(ctx, `
+		SELECT `+checklistColumns+`
+		FROM public.api_checklist_items
+		WHERE id = $1 AND task_id = $2`, itemID, taskID,
+	))
+	if err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+	if in.Position != nil {
+		if err := placeChecklistItem(ctx, tx, taskID, itemID, *in.Position); err != nil {
+			writeStoreError(w, err, "checklist item not found")
+			return
+		}
+	}
+	out, err := scanChecklistItem(tx.QueryRowContext(ctx, `
+		UPDATE public.api_checklist_items
+		SET title = COALESCE($1, title), done = COALESCE($2, done), updated_at = timezone('utc'::text, now())
+		WHERE id = $3
+		RETURNING `+checklistColumns, in.Title, in.Done, itemID,
+	))
+	if err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+
+	by := actorFromRequest(r)
+	action, message := "checklist_edit", fmt.Sprintf("%s edited %q on the checklist of task %q", by.ID, out.Title, taskTitle)
+	switch {
+	case out.Done && !before.Done:
+		action, message = "check", fmt.Sprintf("%s checked %q on task %q", by.ID, out.Title, taskTitle)
+	case !out.Done && before.Done:
+		action, message = "uncheck", fmt.Sprintf("%s unchecked %q on task %q", by.ID, out.Title, taskTitle)
+	}
+	if err := auditChecklistChange(ctx, tx, by, taskID, action, message, &before, &out); err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) deleteChecklistItem(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	taskID, itemID := r.PathValue("id"), r.PathValue("itemID")
+	if !isUUID(taskID) || !isUUID(itemID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "checklist item not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	taskTitle, err := lockTaskRow(ctx, tx, taskID)
+	if err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+	out, err := scanChecklistItem(tx.QueryRowContext(ctx, `
+		DELETE FROM public.api_checklist_items
+		WHERE id = $1 AND task_id = $2
+		RETURNING `+checklistColumns, itemID, taskID,
+	))
+	if err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+	by := actorFromRequest(r)
+	message := fmt.Sprintf("%s removed %q from the checklist of task %q", by.ID, out.Title, taskTitle)
+	if err := auditChecklistChange(ctx, tx, by, taskID, "checklist_delete", message, &out, nil); err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "checklist item not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
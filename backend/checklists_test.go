@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMoveIDReordersAndClamps(t *testing.T) {
+	ids := []string{"a", "b", "c", "d"}
+	cases := []struct {
+		id    string
+		index int
+		want  []string
+	}{
+		{"d", 0, []string{"d", "a", "b", "c"}},
+		{"a", 2, []string{"b", "c", "a", "d"}},
+		{"b", 99, []string{"a", "c", "d", "b"}},
+		{"c", -1, []string{"c", "a", "b", "d"}},
+		{"e", 1, []string{"a", "e", "b", "c", "d"}},
+	}
+	for _, tc := range cases {
+		if got := moveID(ids, tc.id, tc.index); !slices.Equal(got, tc.want) {
+			t.Errorf("moveID(%s, %d) = %v, want %v", tc.id, tc.index, got, tc.want)
+		}
+	}
+}
+
+func TestNewTaskProgressCombinesCounts(t *testing.T) {
+	p := newTaskProgress(progressCount{Done: 2, Total: 3}, progressCount{Done: 1, Total: 3})
+	if p.Done != 3 || p.Total != 6 || p.Percent != 50 {
+		t.Fatalf("expected 3 of 6 (50%%), got %d of %d (%d%%)", p.Done, p.Total, p.Percent)
+	}
+	if empty := newTaskProgress(progressCount{}, progressCount{}); empty.Percent != 0 {
+		t.Fatalf("expected 0%% without items, got %d%%", empty.Percent)
+	}
+}
+
+func TestParentFilter(t *testing.T) {
+	where := &whereBuilder{}
+	if err := parentFilter(where, url.Values{"parent": {"none"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(where.sql(), "parent_id IS NULL") {
+		t.Fatalf("expected top-level filter, got %s", where.sql())
+	}
+
+	where = &whereBuilder{}
+	id := "3f2b7a4e-1c2d-4e5f-8a9b-0c1d2e3f4a5b"
+	if err := parentFilter(where, url.Values{"parent_id": {id}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(where.args) != 1 || where.args[0] != id {
+		t.Fatalf("expected parent id argument, got %v", where.args)
+	}
+
+	if err := parentFilter(&whereBuilder{}, url.Values{"parent": {"nope"}}); err == nil {
+		t.Fatal("expected an invalid parent to be rejected")
+	}
+}
+
+func TestParseChecklistTitle(t *testing.T) {
+	if got, err := parseChecklistTitle("  write tests "); err != nil || got != "write tests" {
+		t.Fatalf("expected trimmed title, got %q, %v", got, err)
+	}
+	if _, err := parseChecklistTitle("   "); err == nil {
+		t.Fatal("expected an empty title to be rejected")
+	}
+	if _, err := parseChecklistTitle(strings.Repeat("x", maxChecklistTitleLength+1)); err == nil {
+		t.Fatal("expected an overlong title to be rejected")
+	}
+}
+
+func TestDeleteChecklistItemAuditsTask(t *testing.T) {
+	f, db := newFakeDB(t)
+	taskID, itemID := "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11", "7a1d2e3f-4b5c-4d6e-8f70-819203a4b5c6"
+	f.answer("SELECT title, archived_at IS NOT NULL", []string{"title", "archived"}, []driver.Value{"Ship it", false})
+	f.answer("DELETE FROM public.api_checklist_items", []string{"id", "task_id", "title", "done", "position", "created_at", "updated_at"},
+		[]driver.Value{itemID, taskID, "Write docs", true, int64(0), time.Now(), time.Now()})
+
+	s := &server{db: db}
+	r := httptest.NewRequest(http.MethodDelete, "/api/tasks/"+taskID+"/checklist/"+itemID, nil)
+	r.SetPathValue("id", taskID)
+	r.SetPathValue("itemID", itemID)
+	w := httptest.NewRecorder()
+	s.taskChecklistItem(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	bumps := f.find("SET version = version + 1")
+	history := f.find("INSERT INTO public.api_task_history")
+	if len(bumps) != 1 || len(history) != 1 {
+		t.Fatalf("expected a version bump and a history entry, got %+v and %+v", bumps, history)
+	}
+	if bumps[0].Tx == 0 || history[0].Tx != bumps[0].Tx {
+		t.Fatalf("expected the audit inside the deleting transaction, got %+v and %+v", bumps, history)
+	}
+	if history[0].Args[1] != "checklist_delete" {
+		t.Fatalf("unexpected history action %v", history[0].Args[1])
+	}
+}
@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// claimSchema records agent work claims. A lease is active until it is
+// released or its expiry passes; the partial unique index guarantees a task
+// has at most one unreleased lease, so two agents never hold the same card.
+var claimSchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_task_leases (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		task_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		agent_id TEXT NOT NULL,
+		ttl_seconds INTEGER NOT NULL,
+		claimed_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		heartbeat_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		expires_at TIMESTAMPTZ NOT NULL,
+		released_at TIMESTAMPTZ,
+		release_reason TEXT
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS api_task_leases_active_idx ON public.api_task_leases (task_id) WHERE released_at IS NULL`,
+	`CREATE INDEX IF NOT EXISTS api_task_leases_expiry_idx ON public.api_task_leases (expires_at) WHERE released_at IS NULL`,
+}
+
+const (
+	defaultLeaseTTL = 15 * time.Minute
+	minLeaseTTL     = 30 * time.Second
+	maxLeaseTTL     = 24 * time.Hour
+)
+
+// sweeperActor attributes changes made by background jobs.
+var sweeperActor = actor{Type: "system", ID: "sweeper"}
+
+type lease struct {
+	ID            string     `json:"id"`
+	TaskID        string     `json:"task_id"`
+	AgentID       string     `json:"agent_id"`
+	TTLSeconds    int        `json:"ttl_seconds"`
+	ClaimedAt     time.Time  `json:"claimed_at"`
+	HeartbeatAt   time.Time  `json:"heartbeat_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	ReleasedAt    *time.Time `json:"released_at"`
+	ReleaseReason *string    `json:"release_reason"`
+}
+
+const leaseColumns = `id::text, task_id::text, agent_id, ttl_seconds, claimed_at, heartbeat_at, expires_at, released_at, release_reason`
+
+func scanLease(row rowScanner) (lease, error) {
+	var l lease
+	err := row.Scan(&l.ID, &l.TaskID, &l.AgentID, &l.TTLSeconds, &l.ClaimedAt, &l.HeartbeatAt, &l.ExpiresAt, &l.ReleasedAt, &l.ReleaseReason)
+	return l, err
+}
+
+// loadLeaseTTL reads TASK_LEASE_TTL, the lease granted when a claim does not
+// ask for one.
+func loadLeaseTTL() (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv("TASK_LEASE_TTL"))
+	if v == "" {
+		return defaultLeaseTTL, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < minLeaseTTL || d > maxLeaseTTL {
+		return 0, fmt.Errorf("invalid TASK_LEASE_TTL %q, expected a duration between %s and %s", v, minLeaseTTL, maxLeaseTTL)
+	}
+	return d, nil
+}
+
+func (s *server) taskLeaseTTL() time.Duration {
+	if s.leaseTTL == 0 {
+		return defaultLeaseTTL
+	}
+	return s.leaseTTL
+}
+
+// claimRequest narrows which tasks a claim may hand out. Every field is
+// optional.
+type claimRequest struct {
+	BoardID      string   `json:"board_id"`
+	ColumnID     string   `json:"column_id"`
+	LabelIDs     []string `json:"label_ids"`
+	LeaseSeconds int      `json:"lease_seconds"`
+}
+
+func (in claimRequest) validate() error {
+	for name, id := range map[string]string{"board_id": in.BoardID, "column_id": in.ColumnID} {
+		if id != "" && !isUUID(id) {
+			return fmt.Errorf("invalid %s", name)
+		}
+	}
+	for _, id := range in.LabelIDs {
+		if !isUUID(id) {
+			return fmt.Errorf("invalid label id %q", id)
+		}
+	}
+	if in.LeaseSeconds != 0 {
+		ttl := time.Duration(in.LeaseSeconds) * time.Second
+		if ttl < minLeaseTTL || ttl > maxLeaseTTL {
+			return fmt.Errorf("lease_seconds must be between %d and %d", int(minLeaseTTL/time.Second), int(maxLeaseTTL/time.Second))
+		}
+	}
+	return nil
+}
+
+// claimFilter selects the tasks an agent may claim: tasks in the initial
+// workflow state that are unassigned or already assigned to the agent, hold
+// no live lease, are not archived, are not waiting on open blockers and sit
+// in a column where the agent is below its per-agent WIP limit.
+func claimFilter(in claimRequest, agentID string, wf *workflow) *whereBuilder {
+	where := &whereBuilder{}
+	where.add("status = " + where.arg(wf.Initial))
+	where.add("archived_at IS NULL")
+	agent := where.arg(agentID)
+	where.add("(assigned_to IS NULL OR assigned_to = " + agent + ")")
+	where.add(`(assigned_to IS NOT NULL OR NOT EXISTS (
+		SELECT 1 FROM public.api_columns c
+		WHERE c.id = api_tasks.column_id AND c.wip_limit_per_agent IS NOT NULL
+			AND (SELECT count(*) FROM public.api_tasks o
+				WHERE o.column_id = c.id AND o.archived_at IS NULL AND o.assigned_to = ` + agent + `) >= c.wip_limit_per_agent))`)
+	where.add(`NOT EXISTS (
+		SELECT 1 FROM public.api_task_leases le
+		WHERE le.task_id = api_tasks.id AND le.released_at IS NULL AND le.expires_at > now())`)
+	where.add(`NOT EXISTS (
+		SELECT 1 FROM public.api_task_dependencies d
+		JOIN public.api_tasks b ON b.id = d.blocked_by_id
+		WHERE d.task_id = api_tasks.id AND b.archived_at IS NULL AND b.status <> ALL(` + where.arg(wf.finalStates()) + `))`)
+	if in.ColumnID != "" {
+		where.add("column_id = " + where.arg(in.ColumnID))
+	}
+	if in.BoardID != "" {
+		where.add("column_id IN (SELECT id FROM public.api_columns WHERE board_id = " + where.arg(in.BoardID) + ")")
+	}
+	if len(in.LabelIDs) > 0 {
+		where.add(`EXISTS (
+			SELECT 1 FROM public.api_task_labels tl
+			WHERE tl.task_id = api_tasks.id AND tl.label_id = ANY(` + where.arg(in.LabelIDs) + `::uuid[]))`)
+	}
+	return where
+}
+
+// claimAgent returns the agent making a claim request; leases are only
+// handed to agents.
+func claimAgent(w http.ResponseWriter, r *http.Request) (actor, bool) {
+	by := actorFromRequest(r)
+	if by.Type != "agent" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "X-Agent-ID header is required"})
+		return by, false
+	}
+	return by, true
+}
+
+// claimTask hands the oldest eligible task to the calling agent. SKIP LOCKED
+// lets concurrent claims pass over a task another agent is claiming instead
+// of queueing behind it. It answers 204 when nothing is eligible.
+func (s *server) claimTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	by, ok := claimAgent(w, r)
+	if !ok {
+		return
+	}
+	var in claimRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	if err := in.validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ttl := s.taskLeaseTTL()
+	if in.LeaseSeconds != 0 {
+		ttl = time.Duration(in.LeaseSeconds) * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// The filter's per-agent WIP check counts the agent's cards, which a
+	// concurrent claim by the same agent may be about to change. Before
+	// taking an unassigned task the claim locks its column for the agent and
+	// selects again, so the count includes every claim committed before.
+	where := claimFilter(in, by.ID, s.taskWorkflow())
+	var before task
+	locked := make(map[string]bool)
+	for {
+		before, err = scanTask(tx.QueryRowContext(ctx, `
+			SELECT `+taskColumns+`
+			FROM public.api_tasks
+			`+where.sql()+`
+			ORDER BY created_at ASC, id ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED`, where.args...,
+		))
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		if before.AssignedTo != nil || before.ColumnID == nil || locked[*before.ColumnID] {
+			break
+		}
+		if err := lockAgentWIP(ctx, tx, *before.ColumnID, by.ID); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		locked[*before.ColumnID] = true
+	}
+
+	// A lapsed lease the sweeper has not released yet no longer protects the
+	// task; close it so the new lease can take its place.
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE public.api_task_leases
+		SET released_at = timezone('utc'::text, now()), release_reason = 'expired'
+		WHERE task_id = $1 AND released_at IS NULL`, before.ID,
+	); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	out, err := scanLease(tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_task_leases (task_id, agent_id, ttl_seconds, expires_at)
+		VALUES ($1, $2, $3::integer, now() + $3::integer * interval '1 second')
+		RETURNING `+leaseColumns, before.ID, by.ID, int(ttl/time.Second),
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	after, err := scanTask(tx.QueryRowContext(ctx, `
+		UPDATE public.api_tasks
+		SET assigned_to = $1, version = version + 1, updated_at = timezone('utc'::text, now())
+		WHERE id = $2
+		RETURNING `+taskColumns, by.ID, before.ID,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := recordLeaseChange(ctx, tx, by, &before, &after, out, "claim"); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(after))
+	writeJSON(w, http.StatusOK, map[string]any{"task": after, "lease": out})
+}
+
+func (s *server) taskLease(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getLease(w, r)
+	case http.MethodDelete:
+		s.releaseLease(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) getLease(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	out, err := scanLease(s.db.QueryRowContext(ctx, `
+		SELECT `+leaseColumns+`
+		FROM public.api_task_leases
+		WHERE task_id = $1 AND released_at IS NULL AND expires_at > now()`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task has no active lease")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// heartbeatLease extends the caller's lease by its original duration. A
+// lease that has already lapsed cannot be renewed; the agent must claim again.
+func (s *server) heartbeatLease(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	by, ok := claimAgent(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := lockLease(ctx, tx, id, by)
+	if err != nil {
+		writeStoreError(w, err, "task has no active lease")
+		return
+	}
+	out, err := scanLease(tx.QueryRowContext(ctx, `
+		UPDATE public.api_task_leases
+		SET heartbeat_at = timezone('utc'::text, now()), expires_at = now() + ttl_seconds * interval '1 second'
+		WHERE id = $1
+		RETURNING `+leaseColumns, current.ID,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task has no active lease")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task has no active lease")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// releaseLease gives a claimed task back before its lease lapses.
+func (s *server) releaseLease(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	by, ok := claimAgent(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := lockTask(ctx, tx, id, "")
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	current, err := lockLease(ctx, tx, id, by)
+	if err != nil {
+		writeStoreError(w, err, "task has no active lease")
+		return
+	}
+	out, after, err := s.endLease(ctx, tx, by, before, current, "released")
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(after))
+	writeJSON(w, http.StatusOK, map[string]any{"task": after, "lease": out})
+}
+
+// lockLease locks the live lease on a task and checks that by holds it.
+func lockLease(ctx context.Context, tx *sql.Tx, taskID string, by actor) (lease, error) {
+	l, err := scanLease(tx.QueryRowContext(ctx, `
+		SELECT `+leaseColumns+`
+		FROM public.api_task_leases
+		WHERE task_id = $1 AND released_at IS NULL
+		FOR UPDATE`, taskID,
+	))
+	if err != nil {
+		return l, err
+	}
+	if l.AgentID != by.ID {
+		return l, &statusError{status: http.StatusForbidden, body: map[string]any{
+			"error":    "task is claimed by another agent",
+			"agent_id": l.AgentID,
+		}}
+	}
+	if !l.ExpiresAt.After(time.Now()) {
+		return l, newStatusError(http.StatusConflict, "lease has expired, claim the task again")
+	}
+	return l, nil
+}
+
+// endLease closes a lease on the locked task t and hands the task back: the
+// agent is unassigned and, unless the task is finished, it returns to the
+// initial state when the workflow allows it so another agent can claim it.
+func (s *server) endLease(ctx context.Context, tx *sql.Tx, by actor, t task, l lease, reason string) (lease, task, error) {
+	out, err := scanLease(tx.QueryRowContext(ctx, `
+		UPDATE public.api_task_leases
+		SET released_at = timezone('utc'::text, now()), release_reason = $1
+		WHERE id = $2
+		RETURNING `+leaseColumns, reason, l.ID,
+	))
+	if err != nil {
+		return out, t, err
+	}
+
+	wf := s.taskWorkflow()
+	if wf.isFinal(t.Status) {
+		return out, t, recordLeaseChange(ctx, tx, by, &t, &t, out, reason)
+	}
+	status := t.Status
+	if wf.checkTransition(t.Status, wf.Initial) == nil {
+		status = wf.Initial
+	}
+	after, err := scanTask(tx.QueryRowContext(ctx, `
+		UPDATE public.api_tasks
+		SET assigned_to = CASE WHEN assigned_to = $1 THEN NULL ELSE assigned_to END,
+			status = $2, version = version + 1, updated_at = timezone('utc'::text, now())
+		WHERE id = $3
+		RETURNING `+taskColumns, l.AgentID, status, t.ID,
+	))
+	if err != nil {
+		return out, t, err
+	}
+	return out, after, recordLeaseChange(ctx, tx, by, &t, &after, out, reason)
+}
+
+// recordLeaseChange appends the task history for a claim or release and
+// writes a single activity row naming the lease event.
+func recordLeaseChange(ctx context.Context, tx *sql.Tx, by actor, before, after *task, l lease, action string) error {
+	if _, err := recordTaskHistory(ctx, tx, by, before, after); err != nil {
+		return err
+	}
+	var message string
+	switch action {
+	case "claim":
+		message = fmt.Sprintf("%s claimed task %q until %s", l.AgentID, after.Title, l.ExpiresAt.UTC().Format(time.RFC3339))
+	case "expired":
+		message = fmt.Sprintf("lease of %s on task %q lapsed and was released", l.AgentID, after.Title)
+	default:
+		message = fmt.Sprintf("%s released task %q", l.AgentID, after.Title)
+	}
+	return insertActivityLog(ctx, tx, by, after.ID, message, map[string]any{
+		"actions":  []string{action},
+		"lease_id": l.ID,
+		"agent_id": l.AgentID,
+	})
+}
+
+// releaseExpiredLeases releases every lease whose expiry has passed. Each
+// lease is handled in its own transaction, taking locks in the same order as
+// request handlers, so one stuck task does not hold up the rest.
+func (s *server) releaseExpiredLeases(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT task_id::text
+		FROM public.api_task_leases
+		WHERE released_at IS NULL AND expires_at <= now()
+		ORDER BY expires_at
+		LIMIT 500`)
+	if err != nil {
+		return err
+	}
+	var taskIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		taskIDs = append(taskIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range taskIDs {
+		if err := s.releaseExpiredLease(ctx, id); err != nil {
+			log.Printf("release lease on task %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *server) releaseExpiredLease(ctx context.Context, taskID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	t, err := lockTask(ctx, tx, taskID, "")
+	if err != nil {
+		return err
+	}
+	// The lease may have been renewed or released since it was listed.
+	l, err := scanLease(tx.QueryRowContext(ctx, `
+		SELECT `+leaseColumns+`
+		FROM public.api_task_leases
+		WHERE task_id = $1 AND released_at IS NULL AND expires_at <= now()
+		FOR UPDATE`, taskID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, _, err := s.endLease(ctx, tx, sweeperActor, t, l, "expired"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
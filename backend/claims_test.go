@@ -0,0 +1,81 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClaimRequestValidate(t *testing.T) {
+	valid := claimRequest{BoardID: "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11", LeaseSeconds: 600}
+	if err := valid.validate(); err != nil {
+		t.Fatalf("expected a valid claim, got %v", err)
+	}
+	for _, in := range []claimRequest{
+		{ColumnID: "col-1"},
+		{LabelIDs: []string{"bug"}},
+		{LeaseSeconds: 5},
+		{LeaseSeconds: 7 * 24 * 3600},
+	} {
+		if err := in.validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", in)
+		}
+	}
+}
+
+func TestClaimFilterSkipsLeasedTasks(t *testing.T) {
+	where := claimFilter(claimRequest{}, "arga", defaultWorkflow)
+	sql := where.sql()
+	if !strings.Contains(sql, "api_task_leases") || !strings.Contains(sql, "expires_at > now()") {
+		t.Fatalf("expected live leases to be excluded, got %s", sql)
+	}
+	if where.args[0] != "todo" || where.args[1] != "arga" {
+		t.Fatalf("expected initial state and agent arguments, got %#v", where.args)
+	}
+}
+
+func TestClaimTaskLocksAgentWIPBeforeClaiming(t *testing.T) {
+	f, db := newFakeDB(t)
+	column := "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11"
+	candidate := task{ID: "t1", ColumnID: &column, Title: "Triage", Status: "todo", Version: 1}
+	agent := "arga"
+	claimed := candidate
+	claimed.AssignedTo, claimed.Version = &agent, 2
+	now := time.Now()
+	f.answer("FOR UPDATE SKIP LOCKED", taskRowColumns, taskRow(candidate))
+	f.answer("INSERT INTO public.api_task_leases", []string{"id", "task_id", "agent_id", "ttl_seconds", "claimed_at", "heartbeat_at", "expires_at", "released_at", "release_reason"},
+		[]driver.Value{"l1", "t1", "arga", int64(600), now, now, now.Add(10 * time.Minute), nil, nil})
+	f.answer("UPDATE public.api_tasks", taskRowColumns, taskRow(claimed))
+
+	s := &server{db: db}
+	r := httptest.NewRequest(http.MethodPost, "/api/tasks/claim", strings.NewReader(`{}`))
+	r.Header.Set("X-Agent-ID", "arga")
+	w := httptest.NewRecorder()
+	s.claimTask(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	// The candidate is selected, the column locked for the agent, and the
+	// candidate selected again under the lock before it is claimed.
+	var steps []string
+	for _, stmt := range f.find("") {
+		switch {
+		case strings.Contains(stmt.Query, "FOR UPDATE SKIP LOCKED"):
+			steps = append(steps, "select")
+		case strings.Contains(stmt.Query, "pg_advisory_xact_lock"):
+			if stmt.Args[0] != column || stmt.Args[1] != "arga" {
+				t.Fatalf("unexpected lock arguments %v", stmt.Args)
+			}
+			steps = append(steps, "lock")
+		case strings.Contains(stmt.Query, "INSERT INTO public.api_task_leases"):
+			steps = append(steps, "lease")
+		}
+	}
+	if got := strings.Join(steps, ","); got != "select,lock,select,lease" {
+		t.Fatalf("steps = %s", got)
+	}
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var commentSchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_task_comments (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		task_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		author_type TEXT NOT NULL,
+		author_id TEXT NOT NULL,
+		body TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_task_comments_task_idx ON public.api_task_comments (task_id, created_at DESC, id DESC)`,
+}
+
+// maxCommentLength bounds comment bodies; longer remarks belong in the task
+// description.
+const maxCommentLength = 10000
+
+type comment struct {
+	ID        string    `json:"id"`
+	TaskID    string    `json:"task_id"`
+	Author    actor     `json:"author"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const commentColumns = `id::text, task_id::text, author_type, author_id, body, created_at, updated_at`
+
+func scanComment(row rowScanner) (comment, error) {
+	var c comment
+	err := row.Scan(&c.ID, &c.TaskID, &c.Author.Type, &c.Author.ID, &c.Body, &c.CreatedAt, &c.UpdatedAt)
+	return c, err
+}
+
+// commentExcerpt shortens a comment body for the activity feed.
+func commentExcerpt(body string) string {
+	const limit = 120
+	body = strings.Join(strings.Fields(body), " ")
+	if r := []rune(body); len(r) > limit {
+		return string(r[:limit]) + "…"
+	}
+	return body
+}
+
+func parseCommentBody(r *http.Request) (string, error) {
+	var in struct {
+		Body string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		return "", errors.New("invalid json")
+	}
+	body := strings.TrimSpace(in.Body)
+	if body == "" {
+		return "", errors.New("body is required")
+	}
+	if len([]rune(body)) > maxCommentLength {
+		return "", fmt.Errorf("body must be at most %d characters", maxCommentLength)
+	}
+	return body, nil
+}
+
+func (s *server) taskComments(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listComments(w, r)
+	case http.MethodPost:
+		s.createComment(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) taskComment(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		s.updateComment(w, r)
+	case http.MethodDelete:
+		s.deleteComment(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) listComments(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	where := &whereBuilder{}
+	where.add("task_id = " + where.arg(id))
+	if err := where.addPageFilters(r.URL.Query(), "created_at", "id"); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+commentColumns+`
+		FROM public.api_task_comments
+		`+where.sql()+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+where.arg(limit+1), where.args...)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]comment, 0)
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPage(items, limit, func(c comment) pageCursor {
+		return pageCursor{CreatedAt: c.CreatedAt, ID: c.ID}
+	}))
+}
+
+func (s *server) createComment(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	by := actorFromRequest(r)
+	if by == anonymousActor {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "X-Agent-ID or X-User-ID header is required"})
+		return
+	}
+	body, err := parseCommentBody(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var title string
+	if err := tx.QueryRowContext(ctx, `
+		SELECT title
+		FROM public.api_tasks
+		WHERE id = $1
+		FOR SHARE`, id,
+	).Scan(&title); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	out, err := scanComment(tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_task_comments (task_id, author_type, author_id, body)
+		VALUES ($1, $2, $3, $4)
+		RETURNING `+commentColumns, id, by.Type, by.ID, body,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	message := fmt.Sprintf("%s commented on task %q: %s", by.ID, title, commentExcerpt(body))
+	if err := insertActivityLog(ctx, tx, by, id, message, map[string]any{
+		"actions":    []string{"comment"},
+		"comment_id": out.ID,
+	}); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (s *server) updateComment(w http.ResponseWriter, r *http.Request) {
+	s.changeComment(w, r, "comment_edit")
+}
+
+func (s *server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	s.changeComment(w, r, "comment_delete")
+}
+
+// changeComment edits or deletes a comment on behalf of its author.
+func (s *server) changeComment(w http.ResponseWriter, r *http.Request, action string) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	taskID, commentID := r.PathValue("id"), r.PathValue("commentID")
+	if !isUUID(taskID) || !isUUID(commentID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "comment not found"})
+		return
+	}
+	by := actorFromRequest(r)
+	if by == anonymousActor {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "X-Agent-ID or X-User-ID header is required"})
+		return
+	}
+	var body string
+	if action == "comment_edit" {
+		var err error
+		if body, err = parseCommentBody(r); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	current, err := scanComment(tx.QueryRowContext(ctx, `
+		SELECT `+commentColumns+`
+		FROM public.api_task_comments
+		WHERE id = $1 AND task_id = $2
+		FOR UPDATE`, commentID, taskID,
+	))
+	if err != nil {
+		writeStoreError(w, err, "comment not found")
+		return
+	}
+	if current.Author != by {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "only the author can change this comment"})
+		return
+	}
+
+	var out comment
+	var message string
+	if action == "comment_edit" {
+		out, err = scanComment(tx.QueryRowContext(ctx, `
+			UPDATE public.api_task_comments
+			SET body = $1, updated_at = timezone('utc'::text, now())
+			WHERE id = $2
+			RETURNING `+commentColumns, body, commentID,
+		))
+		message = fmt.Sprintf("%s edited a comment: %s", by.ID, commentExcerpt(body))
+	} else {
+		out, err = scanComment(tx.QueryRowContext(ctx, `
+			DELETE FROM public.api_task_comments
+			WHERE id = $1
+			RETURNING `+commentColumns, commentID,
+		))
+		message = fmt.Sprintf("%s deleted a comment", by.ID)
+	}
+	if err != nil {
+		writeStoreError(w, err, "comment not found")
+		return
+	}
+	if err := insertActivityLog(ctx, tx, by, taskID, message, map[string]any{
+		"actions":    []string{action},
+		"comment_id": commentID,
+	}); err != nil {
+		writeStoreError(w, err, "comment not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "comment not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
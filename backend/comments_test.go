@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCommentExcerptCollapsesAndTruncates(t *testing.T) {
+	if got := commentExcerpt("  looks\n\tgood  "); got != "looks good" {
+		t.Fatalf("expected whitespace to be collapsed, got %q", got)
+	}
+	got := commentExcerpt(strings.Repeat("a", 200))
+	if !strings.HasSuffix(got, "…") || len([]rune(got)) != 121 {
+		t.Fatalf("expected truncated excerpt, got %q", got)
+	}
+}
+
+func TestParseCommentBodyValidates(t *testing.T) {
+	for _, body := range []string{`{"body":"   "}`, `not json`, `{"body":"` + strings.Repeat("x", maxCommentLength+1) + `"}`} {
+		r := httptest.NewRequest(http.MethodPost, "/api/tasks/x/comments", strings.NewReader(body))
+		if _, err := parseCommentBody(r); err == nil {
+			t.Fatalf("expected body %.20q to be rejected", body)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/tasks/x/comments", strings.NewReader(`{"body":" ship it "}`))
+	got, err := parseCommentBody(r)
+	if err != nil || got != "ship it" {
+		t.Fatalf("expected trimmed body, got %q (%v)", got, err)
+	}
+}
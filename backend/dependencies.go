@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// dependencySchema stores "task_id is blocked by blocked_by_id" edges. The
+// API keeps the graph acyclic; the CHECK only guards the trivial self-loop.
+var dependencySchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_task_dependencies (
+		task_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		blocked_by_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		PRIMARY KEY (task_id, blocked_by_id),
+		CHECK (task_id <> blocked_by_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_task_dependencies_blocker_idx ON public.api_task_dependencies (blocked_by_id)`,
+}
+
+type dependencyEdge struct {
+	TaskID      string `json:"task_id"`
+	BlockedByID string `json:"blocked_by_id"`
+}
+
+type dependencyNode struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	Open   bool   `json:"open"`
+}
+
+// dependencyGraph is the transitive neighbourhood of one task: everything it
+// waits on (upstream) and everything waiting on it (downstream).
+type dependencyGraph struct {
+	TaskID       string           `json:"task_id"`
+	Blocked      bool             `json:"blocked"`
+	OpenBlockers []string         `json:"open_blockers"`
+	Nodes        []dependencyNode `json:"nodes"`
+	Edges        []dependencyEdge `json:"edges"`
+}
+
+// findPath returns a path from one task to another following blocked-by
+// edges, or nil when there is none. Adding the edge to->from would close
+// exactly this path into a cycle.
+func findPath(blockedBy map[string][]string, from, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			path := []string{}
+			for ; id != ""; id = prev[id] {
+				path = append([]string{id}, path...)
+			}
+			return path
+		}
+		for _, next := range blockedBy[id] {
+			if _, seen := prev[next]; !seen {
+				prev[next] = id
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// upstreamEdges loads every blocked-by edge reachable from taskID. The
+// recursion carries nothing but the edge, so UNION visits each edge once and
+// terminates on cycles too.
+func upstreamEdges(ctx context.Context, tx *sql.Tx, taskID string) ([]dependencyEdge, error) {
+	return queryEdges(ctx, tx, `
+		WITH RECURSIVE up (task_id, blocked_by_id) AS (
+			SELECT task_id, blocked_by_id
+			FROM public.api_task_dependencies
+			WHERE task_id = $1
+			UNION
+			SELECT d.task_id, d.blocked_by_id
+			FROM public.api_task_dependencies d
+			JOIN up ON d.task_id = up.blocked_by_id
+		)
+		SELECT task_id::text, blocked_by_id::text FROM up`, taskID)
+}
+
+// downstreamEdges loads every edge of tasks transitively blocked by taskID.
+func downstreamEdges(ctx context.Context, tx *sql.Tx, taskID string) ([]dependencyEdge, error) {
+	return queryEdges(ctx, tx, `
+		WITH RECURSIVE down (task_id, blocked_by_id) AS (
+			SELECT task_id, blocked_by_id
+			FROM public.api_task_dependencies
+			WHERE blocked_by_id = $1
+			UNION
+			SELECT d.task_id, d.blocked_by_id
+			FROM public.api_task_dependencies d
+			JOIN down ON d.blocked_by_id = down.task_id
+		)
+		SELECT task_id::text, blocked_by_id::text FROM down`, taskID)
+}
+
+func queryEdges(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]dependencyEdge, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	edges := make([]dependencyEdge, 0)
+	for rows.Next() {
+		var e dependencyEdge
+		if err := rows.Scan(&e.TaskID, &e.BlockedByID); err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// checkBlockers refuses to put a task into a final state while any task it
+// is blocked by is still open. Archived blockers no longer count.
+func checkBlockers(ctx context.Context, tx *sql.Tx, wf *workflow, taskID, status string) error {
+	if !wf.isFinal(status) {
+		return nil
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT t.id::text, t.title, t.status
+		FROM public.api_task_dependencies d
+		JOIN public.api_tasks t ON t.id = d.blocked_by_id
+		WHERE d.task_id = $1 AND t.archived_at IS NULL AND t.status <> ALL($2)
+		ORDER BY t.created_at, t.id`, taskID, wf.finalStates())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	blockers := make([]dependencyNode, 0)
+	for rows.Next() {
+		n := dependencyNode{Open: true}
+		if err := rows.Scan(&n.ID, &n.Title, &n.Status); err != nil {
+			return err
+		}
+		blockers = append(blockers, n)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(blockers) == 0 {
+		return nil
+	}
+	return &statusError{status: http.StatusConflict, body: map[string]any{
+		"error":    fmt.Sprintf("task is blocked by %d open task(s)", len(blockers)),
+		"blockers": blockers,
+	}}
+}
+
+func (s *server) taskDependencies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getDependencies(w, r)
+	case http.MethodPost:
+		s.addDependency(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) taskDependency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	s.removeDependency(w, r)
+}
+
+func (s *server) getDependencies(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM public.api_tasks WHERE id = $1)`, id).Scan(&exists); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if !exists {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	up, err := upstreamEdges(ctx, tx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	down, err := downstreamEdges(ctx, tx, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	graph := dependencyGraph{TaskID: id, OpenBlockers: []string{}, Edges: append(up, down...)}
+	ids := []string{id}
+	for _, e := range graph.Edges {
+		ids = append(ids, e.TaskID, e.BlockedByID)
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id::text, title, status, archived_at IS NOT NULL
+		FROM public.api_tasks
+		WHERE id = ANY($1::uuid[])
+		ORDER BY created_at, id`, ids)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	wf := s.taskWorkflow()
+	open := make(map[string]bool)
+	graph.Nodes = make([]dependencyNode, 0)
+	for rows.Next() {
+		var n dependencyNode
+		var archived bool
+		if err := rows.Scan(&n.ID, &n.Title, &n.Status, &archived); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		n.Open = !archived && !wf.isFinal(n.Status)
+		open[n.ID] = n.Open
+		graph.Nodes = append(graph.Nodes, n)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	for _, e := range up {
+		if e.TaskID == id && open[e.BlockedByID] {
+			graph.Blocked = true
+			graph.OpenBlockers = append(graph.OpenBlockers, e.BlockedByID)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, graph)
+}
+
+func (s *server) addDependency(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	var in struct {
+		BlockedByID string `json:"blocked_by_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	in.BlockedByID = strings.TrimSpace(in.BlockedByID)
+	if !isUUID(in.BlockedByID) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid blocked_by_id"})
+		return
+	}
+	if in.BlockedByID == id {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "a task cannot block itself"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// Cycle checks read the whole upstream graph, so concurrent edge inserts
+	// are serialised; otherwise A->B and B->A could both pass.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('api_task_dependencies'))`); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	titles := make(map[string]string)
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id::text, title
+		FROM public.api_tasks
+		WHERE id = ANY($1::uuid[])`, []string{id, in.BlockedByID})
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	for rows.Next() {
+		var taskID, title string
+		if err := rows.Scan(&taskID, &title); err != nil {
+			rows.Close()
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		titles[taskID] = title
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if _, ok := titles[id]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	if _, ok := titles[in.BlockedByID]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "blocking task not found"})
+		return
+	}
+
+	edges, err := upstreamEdges(ctx, tx, in.BlockedByID)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	blockedBy := make(map[string][]string)
+	for _, e := range edges {
+		blockedBy[e.TaskID] = append(blockedBy[e.TaskID], e.BlockedByID)
+	}
+	if path := findPath(blockedBy, in.BlockedByID, id); path != nil {
+		writeJSON(w, http.StatusConflict, map[string]any{
+			"error": "dependency would create a cycle",
+			"cycle": append([]string{id}, path...),
+		})
+		return
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO public.api_task_dependencies (task_id, blocked_by_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, id, in.BlockedByID,
+	)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	edge := dependencyEdge{TaskID: id, BlockedByID: in.BlockedByID}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		writeJSON(w, http.StatusOK, edge)
+		return
+	}
+	by := actorFromRequest(r)
+	message := fmt.Sprintf("%s marked task %q as blocked by %q", by.ID, titles[id], titles[in.BlockedByID])
+	if err := insertActivityLog(ctx, tx, by, id, message, map[string]any{
+		"actions":       []string{"block"},
+		"blocked_by_id": in.BlockedByID,
+	}); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, edge)
+}
+
+func (s *server) removeDependency(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id, blockerID := r.PathValue("id"), r.PathValue("blockerID")
+	if !isUUID(id) || !isUUID(blockerID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "dependency not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	var title, blockerTitle string
+	if err := tx.QueryRowContext(ctx, `
+		WITH removed AS (
+			DELETE FROM public.api_task_dependencies
+			WHERE task_id = $1 AND blocked_by_id = $2
+			RETURNING task_id, blocked_by_id
+		)
+		SELECT t.title, b.title
+		FROM removed
+		JOIN public.api_tasks t ON t.id = removed.task_id
+		JOIN public.api_tasks b ON b.id = removed.blocked_by_id`, id, blockerID,
+	).Scan(&title, &blockerTitle); err != nil {
+		writeStoreError(w, err, "dependency not found")
+		return
+	}
+	by := actorFromRequest(r)
+	message := fmt.Sprintf("%s removed %q as a blocker of task %q", by.ID, blockerTitle, title)
+	if err := insertActivityLog(ctx, tx, by, id, message, map[string]any{
+		"actions":       []string{"unblock"},
+		"blocked_by_id": blockerID,
+	}); err != nil {
+		writeStoreError(w, err, "dependency not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "dependency not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dependencyEdge{TaskID: id, BlockedByID: blockerID})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestFindPathFollowsBlockers(t *testing.T) {
+	// a is blocked by b, b by c and d, d by e.
+	blockedBy := map[string][]string{
+		"a": {"b"},
+		"b": {"c", "d"},
+		"d": {"e"},
+	}
+	if got := findPath(blockedBy, "a", "e"); !slices.Equal(got, []string{"a", "b", "d", "e"}) {
+		t.Fatalf("expected path a-b-d-e, got %v", got)
+	}
+	if got := findPath(blockedBy, "e", "a"); got != nil {
+		t.Fatalf("expected no path against edge direction, got %v", got)
+	}
+	if got := findPath(blockedBy, "c", "c"); !slices.Equal(got, []string{"c"}) {
+		t.Fatalf("expected a trivial path, got %v", got)
+	}
+}
+
+func TestClaimFilterSkipsBlockedTasks(t *testing.T) {
+	where := claimFilter(claimRequest{}, "arga", defaultWorkflow)
+	got := strings.Join(strings.Fields(where.sql()), " ")
+	want := "NOT EXISTS ( SELECT 1 FROM public.api_task_dependencies d JOIN public.api_tasks b ON b.id = d.blocked_by_id " +
+		"WHERE d.task_id = api_tasks.id AND b.archived_at IS NULL AND b.status <> ALL($3))"
+	if !strings.Contains(got, want) {
+		t.Fatalf("expected tasks with open blockers to be excluded, got %s", got)
+	}
+	if final, ok := where.args[2].([]string); !ok || !slices.Equal(final, defaultWorkflow.finalStates()) {
+		t.Fatalf("expected the final states as $3, got %#v", where.args[2])
+	}
+}
+
+func TestCheckBlockersRejectsOpenBlockers(t *testing.T) {
+	f, db := newFakeDB(t)
+	f.answer("FROM public.api_task_dependencies", []string{"id", "title", "status"},
+		[]driver.Value{"b1", "Blocker", "in_progress"})
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	final := defaultWorkflow.finalStates()[0]
+	err = checkBlockers(context.Background(), tx, defaultWorkflow, "t1", final)
+	var se *statusError
+	if !errors.As(err, &se) || se.status != http.StatusConflict {
+		t.Fatalf("expected a conflict for an open blocker, got %v", err)
+	}
+	queries := f.find("FROM public.api_task_dependencies")
+	if len(queries) != 1 || queries[0].Args[0] != "t1" || !slices.Equal(queries[0].Args[1].([]string), defaultWorkflow.finalStates()) {
+		t.Fatalf("expected blockers outside the final states to be looked up, got %+v", queries)
+	}
+
+	if err := checkBlockers(context.Background(), tx, defaultWorkflow, "t1", defaultWorkflow.Initial); err != nil {
+		t.Fatalf("expected a non-final status to skip the check, got %v", err)
+	}
+	if got := f.find("FROM public.api_task_dependencies"); len(got) != 1 {
+		t.Fatalf("expected no lookup for a non-final status, got %d", len(got))
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dueSchema adds due dates to tasks. overdue_at is set by the sweeper the
+// first time a task is found past due and cleared when due_at changes or the
+// task reaches a final state; api_task_reminders records which lead time
+// reminders were sent for which due date, so changing due_at re-arms them.
+// Sweeps do not bump task versions: overdue_at is derived state.
+var dueSchema = []string{
+	`ALTER TABLE public.api_tasks
+		ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ,
+		ADD COLUMN IF NOT EXISTS overdue_at TIMESTAMPTZ`,
+	`CREATE INDEX IF NOT EXISTS api_tasks_due_idx ON public.api_tasks (due_at) WHERE due_at IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS public.api_task_reminders (
+		task_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		due_at TIMESTAMPTZ NOT NULL,
+		lead_seconds INTEGER NOT NULL,
+		sent_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		PRIMARY KEY (task_id, due_at, lead_seconds)
+	)`,
+}
+
+// sweepConfig controls the background due-date sweeper. Leads are how long
+// before a task's due date a reminder is logged; Retention is how long
+// archived items are kept.
+type sweepConfig struct {
+	Interval  time.Duration
+	Leads     []time.Duration
+	Retention time.Duration
+}
+
+var defaultSweepConfig = sweepConfig{
+	Interval:  time.Minute,
+	Leads:     []time.Duration{24 * time.Hour, time.Hour},
+	Retention: defaultArchiveRetention,
+}
+
+// loadSweepConfig reads TASK_SWEEP_INTERVAL, TASK_REMINDER_LEADS and
+// ARCHIVE_RETENTION_DAYS, e.g. "30s", "24h,1h" and "30". An empty
+// TASK_REMINDER_LEADS keeps the defaults and "none" disables reminders;
+// overdue marking always runs. ARCHIVE_RETENTION_DAYS=0 disables the purge.
+func loadSweepConfig() (sweepConfig, error) {
+	cfg := defaultSweepConfig
+	if v := strings.TrimSpace(os.Getenv("TASK_SWEEP_INTERVAL")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid TASK_SWEEP_INTERVAL %q", v)
+		}
+		cfg.Interval = d
+	}
+	if v := strings.TrimSpace(os.Getenv("TASK_REMINDER_LEADS")); v != "" {
+		leads, err := parseLeadTimes(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid TASK_REMINDER_LEADS: %w", err)
+		}
+		cfg.Leads = leads
+	}
+	if v := strings.TrimSpace(os.Getenv("ARCHIVE_RETENTION_DAYS")); v != "" {
+		retention, err := parseRetentionDays(v)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.Retention = retention
+	}
+	return cfg, nil
+}
+
+func parseLeadTimes(v string) ([]time.Duration, error) {
+	if strings.EqualFold(v, "none") {
+		return nil, nil
+	}
+	var leads []time.Duration
+	for _, part := range strings.Split(v, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		d, err := time.ParseDuration(part)
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("lead time %q must be a duration of at least 1s", part)
+		}
+		if !slices.Contains(leads, d) {
+			leads = append(leads, d)
+		}
+	}
+	return leads, nil
+}
+
+// formatDuration phrases a duration for reminders and time logs, e.g. "1d 2h".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, minutes := int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour), int(d%time.Hour/time.Minute)
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseDueAt validates a due_at value from a request body. An empty string
+// clears the due date.
+func parseDueAt(v string) (*time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid due_at %q, expected RFC 3339", v)
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// overdueFilter handles ?overdue=true|false on GET /api/tasks. A task is
+// overdue once its due date has passed and it is not in a final state; this
+// is evaluated at query time rather than waiting for the next sweep.
+func overdueFilter(where *whereBuilder, q url.Values, wf *workflow) error {
+	v := strings.TrimSpace(q.Get("overdue"))
+	if v == "" {
+		return nil
+	}
+	overdue, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("invalid overdue %q", v)
+	}
+	clause := "(due_at <= now() AND status <> ALL(" + where.arg(wf.finalStates()) + "))"
+	if !overdue {
+		clause = "NOT COALESCE(" + clause + ", false)"
+	}
+	where.add(clause)
+	return nil
+}
+
+// runSweeper periodically marks overdue tasks, sends due-date reminders,
+// releases lapsed work claims, instantiates recurring templates and purges
+// expired archive entries until ctx is cancelled. Each sweep is idempotent,
+// so several server instances may run it against the same database.
+func (s *server) runSweeper(ctx context.Context, cfg sweepConfig) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := s.sweepDue(ctx, cfg.Leads); err != nil && ctx.Err() == nil {
+			log.Printf("due sweep failed: %v", err)
+		}
+		if err := s.releaseExpiredLeases(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("lease sweep failed: %v", err)
+		}
+		if err := s.runTemplates(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("template sweep failed: %v", err)
+		}
+		if err := s.purgeArchive(ctx, cfg.Retention); err != nil && ctx.Err() == nil {
+			log.Printf("archive purge failed: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dueTask is a task picked up by a sweep, with the columns needed to log it.
+type dueTask struct {
+	ID         string
+	Title      string
+	AssignedTo *string
+	DueAt      time.Time
+}
+
+func (s *server) sweepDue(ctx context.Context, leads []time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	final := s.taskWorkflow().finalStates()
+	overdue, err := queryDueTasks(ctx, tx, `
+		UPDATE public.api_tasks
+		SET overdue_at = timezone('utc'::text, now())
+		WHERE due_at <= now() AND overdue_at IS NULL AND archived_at IS NULL AND status <> ALL($1)
+		RETURNING id::text, title, assigned_to, due_at`, final)
+	if err != nil {
+		return err
+	}
+	for _, t := range overdue {
+		message := fmt.Sprintf("Task %q is overdue (due %s)", t.Title, t.DueAt.UTC().Format(time.RFC3339))
+		// The assignee may be a user, so it is kept out of agent_id.
+		if err := insertLogEntry(ctx, tx, nil, t.ID, "WARNING", message, map[string]any{
+			"source":      "sweeper",
+			"actions":     []string{"overdue"},
+			"due_at":      t.DueAt,
+			"assigned_to": t.AssignedTo,
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Smaller leads go first so that a task entering several lead windows in
+	// one sweep, e.g. one created shortly before it is due, is reminded once.
+	leads = slices.Sorted(slices.Values(leads))
+	reminded := make(map[string]bool)
+	for _, lead := range leads {
+		due, err := queryDueTasks(ctx, tx, `
+			WITH sent AS (
+				INSERT INTO public.api_task_reminders (task_id, due_at, lead_seconds)
+				SELECT id, due_at, $1::integer
+				FROM public.api_tasks
+				WHERE due_at > now() AND due_at <= now() + $1::integer * interval '1 second'
+					AND archived_at IS NULL AND status <> ALL($2)
+				ON CONFLICT DO NOTHING
+				RETURNING task_id
+			)
+			SELECT t.id::text, t.title, t.assigned_to, t.due_at
+			FROM sent
+			JOIN public.api_tasks t ON t.id = sent.task_id`, int(lead/time.Second), final)
+		if err != nil {
+			return err
+		}
+		for _, t := range due {
+			if reminded[t.ID] {
+				continue
+			}
+			reminded[t.ID] = true
+			message := fmt.Sprintf("Task %q is due in %s", t.Title, formatDuration(time.Until(t.DueAt)))
+			if err := insertLogEntry(ctx, tx, nil, t.ID, "WARNING", message, map[string]any{
+				"source":       "sweeper",
+				"actions":      []string{"reminder"},
+				"due_at":       t.DueAt,
+				"lead_seconds": int(lead / time.Second),
+				"assigned_to":  t.AssignedTo,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+func queryDueTasks(ctx context.Context, tx *sql.Tx, query string, args ...any) ([]dueTask, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []dueTask
+	for rows.Next() {
+		var t dueTask
+		if err := rows.Scan(&t.ID, &t.Title, &t.AssignedTo, &t.DueAt); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
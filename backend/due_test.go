@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLeadTimes(t *testing.T) {
+	leads, err := parseLeadTimes("24h, 1h,24h")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(leads) != 2 || leads[0] != 24*time.Hour || leads[1] != time.Hour {
+		t.Fatalf("expected de-duplicated leads, got %v", leads)
+	}
+	if leads, err := parseLeadTimes("none"); err != nil || leads != nil {
+		t.Fatalf("expected none to disable reminders, got %v (%v)", leads, err)
+	}
+	for _, v := range []string{"soon", "500ms", "-1h"} {
+		if _, err := parseLeadTimes(v); err == nil {
+			t.Fatalf("expected %q to be rejected", v)
+		}
+	}
+}
+
+func TestFormatLead(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		26 * time.Hour:                  "1d 2h",
+		time.Hour:                       "1h",
+		59*time.Minute + 40*time.Second: "1h",
+		90 * time.Second:                "2m",
+		10 * time.Second:                "0m",
+	} {
+		if got := formatDuration(d); got != want {
+			t.Fatalf("formatDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestOverdueFilterExcludesFinalStates(t *testing.T) {
+	where, err := taskFilter(url.Values{"overdue": {"true"}}, defaultWorkflow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(where.sql(), "due_at <= now()") {
+		t.Fatalf("expected a due date condition, got %s", where.sql())
+	}
+	if final, ok := where.args[0].([]string); !ok || len(final) != 1 || final[0] != "done" {
+		t.Fatalf("expected final states as argument, got %#v", where.args)
+	}
+
+	where, err = taskFilter(url.Values{"overdue": {"false"}}, defaultWorkflow)
+	if err != nil || !strings.HasPrefix(where.clauses[0], "NOT ") {
+		t.Fatalf("expected a negated condition, got %v (%v)", where.clauses, err)
+	}
+	if _, err := taskFilter(url.Values{"overdue": {"maybe"}}, defaultWorkflow); err == nil {
+		t.Fatal("expected an invalid overdue value to be rejected")
+	}
+}
+
+func TestWorkflowFinalStatesDefaultToLastState(t *testing.T) {
+	wf := &workflow{Initial: "open", States: []string{"open", "closed"}}
+	if !wf.isFinal("closed") || wf.isFinal("open") {
+		t.Fatalf("expected the last state to be final, got %v", wf.finalStates())
+	}
+	wf.Final = []string{"shipped"}
+	if err := wf.validate(); err == nil {
+		t.Fatal("expected an undeclared final state to be rejected")
+	}
+}
+
+func TestFinishingTaskClearsOverdue(t *testing.T) {
+	for _, status := range []string{"done", "in_progress"} {
+		f, db := newFakeDB(t)
+		now := time.Now()
+		current := task{ID: "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11", Title: "Ship it", Status: "review", DueAt: &now, OverdueAt: &now, Version: 2}
+		f.answer("FROM public.api_columns", []string{"id", "archived"})
+		f.answer("FROM public.api_tasks", taskRowColumns, taskRow(current))
+
+		s := &server{db: db}
+		r := httptest.NewRequest(http.MethodPatch, "/api/tasks/"+current.ID, strings.NewReader(`{"status":"`+status+`"}`))
+		r.SetPathValue("id", current.ID)
+		w := httptest.NewRecorder()
+		s.taskItem(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", status, w.Code, w.Body)
+		}
+
+		updates := f.find("SET status = $1")
+		if len(updates) != 1 {
+			t.Fatalf("%s: expected one task update, got %+v", status, updates)
+		}
+		cleared := strings.Contains(updates[0].Query, "overdue_at = NULL")
+		if cleared != (status == "done") {
+			t.Errorf("%s: overdue_at cleared = %v in %s", status, cleared, updates[0].Query)
+		}
+	}
+}
+
+func TestSweepDueKeepsAssigneeOutOfAgentID(t *testing.T) {
+	f, db := newFakeDB(t)
+	due := time.Now().Add(-time.Hour)
+	f.answer("SET overdue_at", []string{"id", "title", "assigned_to", "due_at"},
+		[]driver.Value{"5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11", "Ship it", "martha", due})
+
+	s := &server{db: db}
+	if err := s.sweepDue(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	logs := f.find("INSERT INTO public.api_logs")
+	if len(logs) != 1 {
+		t.Fatalf("expected one overdue row, got %+v", logs)
+	}
+	if agentID, _ := logs[0].Args[0].(*string); agentID != nil || !strings.Contains(logs[0].Args[4].(string), `"assigned_to":"martha"`) {
+		t.Fatalf("expected the assignee in metadata rather than agent_id, got %v", logs[0].Args)
+	}
+}
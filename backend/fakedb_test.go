@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDB is a database/sql driver for tests that need no real Postgres: it
+// records every statement with the transaction it ran in and answers queries
+// with canned rows, picked by a substring of the query.
+type fakeDB struct {
+	mu      sync.Mutex
+	stmts   []fakeStmt
+	results []fakeResult
+	txs     int
+}
+
+// fakeStmt is one recorded statement. Tx numbers transactions from 1; 0
+// means the statement ran outside one.
+type fakeStmt struct {
+	Query string
+	Args  []driver.Value
+	Tx    int
+}
+
+type fakeResult struct {
+	match   string
+	columns []string
+	rows    [][]driver.Value
+}
+
+func newFakeDB(t *testing.T) (*fakeDB, *sql.DB) {
+	t.Helper()
+	f := &fakeDB{}
+	db := sql.OpenDB(fakeConnector{f})
+	t.Cleanup(func() { db.Close() })
+	return f, db
+}
+
+// answer makes queries containing match return rows.
+func (f *fakeDB) answer(match string, columns []string, rows ...[]driver.Value) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, fakeResult{match: match, columns: columns, rows: rows})
+}
+
+// find returns the recorded statements containing match.
+func (f *fakeDB) find(match string) []fakeStmt {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []fakeStmt
+	for _, s := range f.stmts {
+		if strings.Contains(s.Query, match) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (f *fakeDB) record(query string, args []driver.NamedValue, tx int) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stmts = append(f.stmts, fakeStmt{Query: query, Args: values, Tx: tx})
+}
+
+type fakeConnector struct{ f *fakeDB }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f: c.f}, nil }
+func (c fakeConnector) Driver() driver.Driver                        { return fakeDriver{} }
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return nil, driver.ErrSkip }
+
+type fakeConn struct {
+	f  *fakeDB
+	tx int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.f.txs++
+	c.tx = c.f.txs
+	return fakeTx{c}, nil
+}
+
+// CheckNamedValue accepts the slices pgx would encode as arrays.
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.f.record(query, args, c.tx)
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.f.record(query, args, c.tx)
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	for _, r := range c.f.results {
+		if strings.Contains(query, r.match) {
+			return &fakeRows{columns: r.columns, rows: r.rows}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+type fakeTx struct{ c *fakeConn }
+
+func (t fakeTx) Commit() error {
+	t.c.tx = 0
+	return nil
+}
+
+func (t fakeTx) Rollback() error {
+	t.c.tx = 0
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+// taskRowColumns and taskRow answer queries selecting taskColumns.
+var taskRowColumns = []string{"id", "column_id", "parent_id", "title", "description", "status", "order", "rank",
+	"assigned_to", "due_at", "overdue_at", "archived_at", "version", "created_at", "updated_at", "labels"}
+
+func taskRow(t task) []driver.Value {
+	labels, _ := json.Marshal(t.Labels)
+	if t.Labels == nil {
+		labels = []byte("[]")
+	}
+	return []driver.Value{t.ID, nullable(t.ColumnID), nullable(t.ParentID), t.Title, t.Description, t.Status,
+		int64(t.Order), nullable(t.Rank), nullable(t.AssignedTo), nullable(t.DueAt), nullable(t.OverdueAt),
+		nullable(t.ArchivedAt), int64(t.Version), t.CreatedAt, t.UpdatedAt, labels}
+}
+
+func nullable[T any](v *T) driver.Value {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
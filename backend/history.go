@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// historySchema creates the append-only audit trail of task mutations. Rows
+// outlive the task they describe, so task_id deliberately has no foreign key.
+var historySchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_task_history (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		task_id UUID NOT NULL,
+		action TEXT NOT NULL,
+		actor_type TEXT NOT NULL,
+		actor_id TEXT NOT NULL,
+		old_values JSONB,
+		new_values JSONB,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_task_history_task_idx ON public.api_task_history (task_id, created_at DESC, id DESC)`,
+	`CREATE OR REPLACE FUNCTION public.api_task_history_append_only() RETURNS trigger
+	LANGUAGE plpgsql AS $$
+	BEGIN
+		RAISE EXCEPTION 'api_task_history is append-only';
+	END $$`,
+	`DROP TRIGGER IF EXISTS api_task_history_append_only ON public.api_task_history`,
+	`CREATE TRIGGER api_task_history_append_only
+		BEFORE UPDATE OR DELETE ON public.api_task_history
+		FOR EACH ROW EXECUTE FUNCTION public.api_task_history_append_only()`,
+}
+
+// actor identifies who made a change. Agents send X-Agent-ID, the dashboard
+// sends X-User-ID; requests with neither are attributed to an anonymous user.
+type actor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+var anonymousActor = actor{Type: "user", ID: "anonymous"}
+
+func actorFromRequest(r *http.Request) actor {
+	if id := strings.TrimSpace(r.Header.Get("X-Agent-ID")); id != "" {
+		return actor{Type: "agent", ID: id}
+	}
+	if id := strings.TrimSpace(r.Header.Get("X-User-ID")); id != "" {
+		return actor{Type: "user", ID: id}
+	}
+	return anonymousActor
+}
+
+type historyEntry struct {
+	ID        string          `json:"id"`
+	TaskID    string          `json:"task_id"`
+	Action    string          `json:"action"`
+	Actor     actor           `json:"actor"`
+	OldValues json.RawMessage `json:"old_values"`
+	NewValues json.RawMessage `json:"new_values"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// taskChange is one audited aspect of a mutation, e.g. a rename or a move.
+type taskChange struct {
+	Action    string
+	OldValues map[string]any
+	NewValues map[string]any
+}
+
+// taskFields returns the audited fields of a task keyed by their JSON name.
+func taskFields(t *task) map[string]any {
+	return map[string]any{
+		"title":       t.Title,
+		"description": t.Description,
+		"status":      t.Status,
+		"column_id":   t.ColumnID,
+		"parent_id":   t.ParentID,
+		"rank":        t.Rank,
+		"assigned_to": t.AssignedTo,
+		"due_at":      t.DueAt,
+		"labels":      t.Labels,
+		"archived_at": t.ArchivedAt,
+	}
+}
+
+// taskFieldActions groups audited fields into the action recorded when they
+// change. Fields not listed here are recorded as a generic update.
+var taskFieldActions = map[string]string{
+	"title":       "rename",
+	"status":      "status",
+	"column_id":   "move",
+	"rank":        "move",
+	"parent_id":   "nest",
+	"due_at":      "due",
+	"labels":      "label",
+	"archived_at": "archive",
+}
+
+// diffTask describes the difference between two snapshots of a task. A nil
+// before means the task was created, a nil after that it was deleted.
+func diffTask(before, after *task) []taskChange {
+	switch {
+	case before == nil && after == nil:
+		return nil
+	case before == nil:
+		return []taskChange{{Action: "create", NewValues: taskFields(after)}}
+	case after == nil:
+		return []taskChange{{Action: "delete", OldValues: taskFields(before)}}
+	}
+
+	oldFields, newFields := taskFields(before), taskFields(after)
+	var changes []taskChange
+	byAction := make(map[string]int)
+	for _, field := range []string{"title", "status", "column_id", "parent_id", "rank", "description", "assigned_to", "due_at", "labels", "archived_at"} {
+		oldValue, _ := json.Marshal(oldFields[field])
+		newValue, _ := json.Marshal(newFields[field])
+		if string(oldValue) == string(newValue) {
+			continue
+		}
+		action, ok := taskFieldActions[field]
+		if !ok {
+			action = "update"
+		}
+		// Bringing a task back from the archive is recorded as its own action.
+		if field == "archived_at" && after.ArchivedAt == nil {
+			action = "restore"
+		}
+		i, ok := byAction[action]
+		if !ok {
+			i = len(changes)
+			byAction[action] = i
+			changes = append(changes, taskChange{Action: action, OldValues: map[string]any{}, NewValues: map[string]any{}})
+		}
+		changes[i].OldValues[field] = oldFields[field]
+		changes[i].NewValues[field] = newFields[field]
+	}
+	return changes
+}
+
+// recordTaskHistory appends one history row per change between before and
+// after, inside the caller's transaction, and returns the changes written.
+func recordTaskHistory(ctx context.Context, tx *sql.Tx, by actor, before, after *task) ([]taskChange, error) {
+	taskID := ""
+	if after != nil {
+		taskID = after.ID
+	} else if before != nil {
+		taskID = before.ID
+	}
+
+	changes := diffTask(before, after)
+	for _, c := range changes {
+		if err := insertHistoryEntry(ctx, tx, by, taskID, c); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// insertHistoryEntry appends one history row inside the caller's transaction.
+func insertHistoryEntry(ctx context.Context, tx *sql.Tx, by actor, taskID string, c taskChange) error {
+	oldValues, err := marshalNullable(c.OldValues)
+	if err != nil {
+		return err
+	}
+	newValues, err := marshalNullable(c.NewValues)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.api_task_history (task_id, action, actor_type, actor_id, old_values, new_values)
+		VALUES ($1, $2, $3, $4, $5::jsonb, $6::jsonb)`,
+		taskID, c.Action, by.Type, by.ID, oldValues, newValues,
+	)
+	return err
+}
+
+// auditTask records a task mutation in the history table and the activity
+// log. Handlers call it once per mutation, inside the mutating transaction.
+func auditTask(ctx context.Context, tx *sql.Tx, by actor, before, after *task) error {
+	changes, err := recordTaskHistory(ctx, tx, by, before, after)
+	if err != nil {
+		return err
+	}
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+	return logTaskMutation(ctx, tx, by, subject, changes)
+}
+
+// marshalNullable encodes v as JSON text, mapping an empty map to SQL NULL.
+func marshalNullable(v map[string]any) (*string, error) {
+	if len(v) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := string(data)
+	return &out, nil
+}
+
+func (s *server) taskHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	where := &whereBuilder{}
+	where.add("task_id = " + where.arg(id))
+	if err := where.addPageFilters(r.URL.Query(), "created_at", "id"); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id::text, task_id::text, action, actor_type, actor_id,
+			COALESCE(old_values, 'null'::jsonb), COALESCE(new_values, 'null'::jsonb), created_at
+		FROM public.api_task_history
+		`+where.sql()+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+where.arg(limit+1), where.args...)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]historyEntry, 0)
+	for rows.Next() {
+		var item historyEntry
+		if err := rows.Scan(&item.ID, &item.TaskID, &item.Action, &item.Actor.Type, &item.Actor.ID, &item.OldValues, &item.NewValues, &item.CreatedAt); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPage(items, limit, func(h historyEntry) pageCursor {
+		return pageCursor{CreatedAt: h.CreatedAt, ID: h.ID}
+	}))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiffTaskGroupsChangesByAction(t *testing.T) {
+	colA, colB := "a", "b"
+	before := &task{ID: "t1", Title: "Old", Status: "todo", ColumnID: &colA}
+	after := &task{ID: "t1", Title: "New", Status: "in_progress", ColumnID: &colB, Description: "details"}
+
+	changes := diffTask(before, after)
+
+	got := make(map[string]taskChange)
+	for _, c := range changes {
+		got[c.Action] = c
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected rename, status, move and update changes, got %+v", changes)
+	}
+	if got["rename"].OldValues["title"] != "Old" || got["rename"].NewValues["title"] != "New" {
+		t.Fatalf("unexpected rename change: %+v", got["rename"])
+	}
+	if got["status"].NewValues["status"] != "in_progress" {
+		t.Fatalf("unexpected status change: %+v", got["status"])
+	}
+	if _, ok := got["move"].NewValues["column_id"]; !ok {
+		t.Fatalf("expected column change to be recorded as a move: %+v", got["move"])
+	}
+
+	if len(diffTask(before, before)) != 0 {
+		t.Fatal("expected no changes between identical snapshots")
+	}
+	if c := diffTask(nil, after); len(c) != 1 || c[0].Action != "create" {
+		t.Fatalf("expected a create change, got %+v", c)
+	}
+	if c := diffTask(before, nil); len(c) != 1 || c[0].Action != "delete" {
+		t.Fatalf("expected a delete change, got %+v", c)
+	}
+}
+
+func TestActorFromRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPatch, "/api/tasks/x", nil)
+	if got := actorFromRequest(r); got != (actor{Type: "user", ID: "anonymous"}) {
+		t.Fatalf("expected anonymous user, got %+v", got)
+	}
+	r.Header.Set("X-User-ID", "danu")
+	if got := actorFromRequest(r); got != (actor{Type: "user", ID: "danu"}) {
+		t.Fatalf("expected user actor, got %+v", got)
+	}
+	r.Header.Set("X-Agent-ID", "arga")
+	if got := actorFromRequest(r); got != (actor{Type: "agent", ID: "arga"}) {
+		t.Fatalf("expected agent actor to take precedence, got %+v", got)
+	}
+}
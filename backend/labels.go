@@ -0,0 +1,564 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// labelSchema creates board-scoped labels and their many-to-many link to
+// tasks. Label names are unique per board regardless of case.
+var labelSchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_labels (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		board_id UUID NOT NULL REFERENCES public.api_boards (id) ON DELETE CASCADE,
+		name TEXT NOT NULL,
+		color TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS api_labels_board_name_idx ON public.api_labels (board_id, lower(name))`,
+	`CREATE TABLE IF NOT EXISTS public.api_task_labels (
+		task_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		label_id UUID NOT NULL REFERENCES public.api_labels (id) ON DELETE CASCADE,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		PRIMARY KEY (task_id, label_id)
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_task_labels_label_idx ON public.api_task_labels (label_id)`,
+}
+
+const (
+	maxLabelNameLength = 50
+	defaultLabelColor  = "#64748b"
+)
+
+var labelColorPattern = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+type label struct {
+	ID        string    `json:"id"`
+	BoardID   string    `json:"board_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// taskLabel is the compact form of a label embedded in task responses.
+type taskLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+const labelColumns = `id::text, board_id::text, name, color, created_at`
+
+// taskLabelsColumn aggregates a task's labels for taskColumns. It refers to
+// the outer row as api_tasks, so task queries must not alias that table.
+const taskLabelsColumn = `COALESCE((
+		SELECT json_agg(json_build_object('id', l.id, 'name', l.name, 'color', l.color) ORDER BY lower(l.name), l.id)
+		FROM public.api_task_labels tl
+		JOIN public.api_labels l ON l.id = tl.label_id
+		WHERE tl.task_id = api_tasks.id
+	), '[]'::json)::text`
+
+func scanLabel(row rowScanner) (label, error) {
+	var l label
+	err := row.Scan(&l.ID, &l.BoardID, &l.Name, &l.Color, &l.CreatedAt)
+	return l, err
+}
+
+func validateLabelName(name string) error {
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if len([]rune(name)) > maxLabelNameLength {
+		return fmt.Errorf("name must be at most %d characters", maxLabelNameLength)
+	}
+	return nil
+}
+
+func validateLabelColor(color string) error {
+	if !labelColorPattern.MatchString(color) {
+		return errors.New("color must be a hex colour such as #3b82f6")
+	}
+	return nil
+}
+
+// labelFilter adds the label conditions of GET /api/tasks: label takes label
+// ids and label_name takes names (case-insensitive). A task matches when it
+// carries any of the requested labels.
+func labelFilter(where *whereBuilder, q url.Values) error {
+	if ids := listParam(q, "label", "labels", "label_id"); len(ids) > 0 {
+		for _, id := range ids {
+			if !isUUID(id) {
+				return fmt.Errorf("invalid label id %q", id)
+			}
+		}
+		where.add(`EXISTS (
+			SELECT 1 FROM public.api_task_labels tl
+			WHERE tl.task_id = api_tasks.id AND tl.label_id = ANY(` + where.arg(ids) + `::uuid[]))`)
+	}
+	if names := listParam(q, "label_name"); len(names) > 0 {
+		for i, name := range names {
+			names[i] = strings.ToLower(name)
+		}
+		where.add(`EXISTS (
+			SELECT 1 FROM public.api_task_labels tl
+			JOIN public.api_labels l ON l.id = tl.label_id
+			WHERE tl.task_id = api_tasks.id AND lower(l.name) = ANY(` + where.arg(names) + `))`)
+	}
+	return nil
+}
+
+// attachLabel links a label to the locked task t and reports whether the
+// link is new. Labels belong to a board, so the task must sit in a column of
+// that same board.
+func attachLabel(ctx context.Context, tx *sql.Tx, t task, labelID string) (bool, error) {
+	var labelBoard string
+	err := tx.QueryRowContext(ctx, `
+		SELECT board_id::text
+		FROM public.api_labels
+		WHERE id = $1
+		FOR SHARE`, labelID,
+	).Scan(&labelBoard)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, newStatusError(http.StatusNotFound, "label not found")
+	}
+	if err != nil {
+		return false, err
+	}
+	if t.ColumnID == nil {
+		return false, newStatusError(http.StatusUnprocessableEntity, "task is not on a board")
+	}
+	var taskBoard string
+	if err := tx.QueryRowContext(ctx, `
+		SELECT board_id::text
+		FROM public.api_columns
+		WHERE id = $1`, *t.ColumnID,
+	).Scan(&taskBoard); err != nil {
+		return false, err
+	}
+	if taskBoard != labelBoard {
+		return false, newStatusError(http.StatusUnprocessableEntity, "label belongs to a different board")
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO public.api_task_labels (task_id, label_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, t.ID, labelID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// detachLabel unlinks a label from a task and reports whether it was linked.
+func detachLabel(ctx context.Context, tx *sql.Tx, taskID, labelID string) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM public.api_task_labels
+		WHERE task_id = $1 AND label_id = $2`, taskID, labelID,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// detachForeignLabels unlinks the labels of other boards from a task that
+// moved into columnID, as labels only apply on their own board.
+func detachForeignLabels(ctx context.Context, tx *sql.Tx, taskID, columnID string) error {
+	_, err := tx.ExecContext(ctx, `
+		DELETE FROM public.api_task_labels tl
+		USING public.api_labels l
+		WHERE tl.task_id = $1 AND l.id = tl.label_id
+			AND l.board_id <> (SELECT board_id FROM public.api_columns WHERE id = $2)`, taskID, columnID,
+	)
+	return err
+}
+
+// touchTask bumps the version of a task whose representation changed without
+// an UPDATE of its own row, such as a label being attached.
+func touchTask(ctx context.Context, tx *sql.Tx, taskID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE public.api_tasks
+		SET version = version + 1, updated_at = timezone('utc'::text, now())
+		WHERE id = $1`, taskID,
+	)
+	return err
+}
+
+func (s *server) boardLabels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listLabels(w, r)
+	case http.MethodPost:
+		s.createLabel(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) labelItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		s.updateLabel(w, r)
+	case http.MethodDelete:
+		s.deleteLabel(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) taskLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	var in struct {
+		LabelID string `json:"label_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	in.LabelID = strings.TrimSpace(in.LabelID)
+	if !isUUID(in.LabelID) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid label_id"})
+		return
+	}
+	s.changeTaskLabel(w, r, in.LabelID, true)
+}
+
+func (s *server) taskLabel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	labelID := r.PathValue("labelID")
+	if !isUUID(labelID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "label not found"})
+		return
+	}
+	s.changeTaskLabel(w, r, labelID, false)
+}
+
+func (s *server) listLabels(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+labelColumns+`
+		FROM public.api_labels
+		WHERE board_id = $1
+		ORDER BY lower(name) ASC, id ASC`, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]label, 0)
+	for rows.Next() {
+		l, err := scanLabel(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, l)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) createLabel(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	var in struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	in.Name = strings.TrimSpace(in.Name)
+	in.Color = strings.TrimSpace(in.Color)
+	if in.Color == "" {
+		in.Color = defaultLabelColor
+	}
+	for _, err := range []error{validateLabelName(in.Name), validateLabelColor(in.Color)} {
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	out, err := scanLabel(s.db.QueryRowContext(ctx, `
+		INSERT INTO public.api_labels (board_id, name, color)
+		VALUES ($1, $2, lower($3))
+		RETURNING `+labelColumns, id, in.Name, in.Color,
+	))
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (s *server) updateLabel(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "label not found"})
+		return
+	}
+
+	var in struct {
+		Name  *string `json:"name"`
+		Color *string `json:"color"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if err := validateLabelName(name); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		set("name", name)
+	}
+	if in.Color != nil {
+		color := strings.ToLower(strings.TrimSpace(*in.Color))
+		if err := validateLabelColor(color); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		set("color", color)
+	}
+	if len(sets) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no fields to update"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	args = append(args, id)
+	out, err := scanLabel(s.db.QueryRowContext(ctx, `
+		UPDATE public.api_labels
+		SET `+strings.Join(sets, ", ")+`
+		WHERE id = $`+fmt.Sprint(len(args))+`
+		RETURNING `+labelColumns, args...,
+	))
+	if err != nil {
+		writeStoreError(w, err, "label not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) deleteLabel(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "label not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	// The tasks carrying the label change too: they get a new version and a
+	// history entry like any other label removal.
+	before := make(map[string]task)
+	err = eachRow(ctx, tx, func(rows *sql.Rows) error {
+		t, err := scanTask(rows)
+		before[t.ID] = t
+		return err
+	}, `SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id IN (SELECT task_id FROM public.api_task_labels WHERE label_id = $1)
+		ORDER BY id
+		FOR UPDATE`, id)
+	if err != nil {
+		writeStoreError(w, err, "label not found")
+		return
+	}
+
+	out, err := scanLabel(tx.QueryRowContext(ctx, `
+		DELETE FROM public.api_labels
+		WHERE id = $1
+		RETURNING `+labelColumns, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "label not found")
+		return
+	}
+
+	if len(before) > 0 {
+		ids := make([]string, 0, len(before))
+		for taskID := range before {
+			ids = append(ids, taskID)
+		}
+		var touched []task
+		err = eachRow(ctx, tx, func(rows *sql.Rows) error {
+			t, err := scanTask(rows)
+			touched = append(touched, t)
+			return err
+		}, `UPDATE public.api_tasks
+			SET version = version + 1, updated_at = timezone('utc'::text, now())
+			WHERE id = ANY($1::uuid[])
+			RETURNING `+taskColumns, ids)
+		if err != nil {
+			writeStoreError(w, err, "label not found")
+			return
+		}
+		by := actorFromRequest(r)
+		for _, after := range touched {
+			prev := before[after.ID]
+			if err := auditTask(ctx, tx, by, &prev, &after); err != nil {
+				writeStoreError(w, err, "label not found")
+				return
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "label not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// changeTaskLabel attaches or detaches one label as an audited task
+// mutation. Repeating an attach or detach is a no-op that returns the task.
+func (s *server) changeTaskLabel(w http.ResponseWriter, r *http.Request, labelID string, attach bool) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := lockTask(ctx, tx, id, "")
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if !checkTaskPrecondition(w, r, before) {
+		return
+	}
+
+	var changed bool
+	if attach {
+		changed, err = attachLabel(ctx, tx, before, labelID)
+	} else {
+		changed, err = detachLabel(ctx, tx, id, labelID)
+	}
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if changed {
+		if err := touchTask(ctx, tx, id); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+
+	out, err := scanTask(tx.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := auditTask(ctx, tx, actorFromRequest(r), &before, &out); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(out))
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateLabelColor(t *testing.T) {
+	for _, color := range []string{"#fff", "#3B82F6", defaultLabelColor} {
+		if err := validateLabelColor(color); err != nil {
+			t.Fatalf("expected %q to be accepted: %v", color, err)
+		}
+	}
+	for _, color := range []string{"", "red", "#12345", "3b82f6", "#ggg"} {
+		if err := validateLabelColor(color); err == nil {
+			t.Fatalf("expected %q to be rejected", color)
+		}
+	}
+}
+
+func TestTaskFilterByLabel(t *testing.T) {
+	id := "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11"
+	where, err := taskFilter(url.Values{"label": {id}, "label_name": {"Bug,Frontend"}}, defaultWorkflow)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sql := where.sql()
+	if strings.Count(sql, "api_task_labels") != 2 {
+		t.Fatalf("expected an EXISTS clause per label filter, got %s", sql)
+	}
+	names, ok := where.args[1].([]string)
+	if !ok || len(names) != 2 || names[0] != "bug" || names[1] != "frontend" {
+		t.Fatalf("expected lower-cased label names, got %#v", where.args[1])
+	}
+
+	if _, err := taskFilter(url.Values{"label": {"not-a-uuid"}}, defaultWorkflow); err == nil {
+		t.Fatal("expected an invalid label id to be rejected")
+	}
+}
+
+func TestDiffTaskRecordsLabelChanges(t *testing.T) {
+	before := &task{ID: "t1", Title: "Fix login", Labels: []taskLabel{}}
+	after := &task{ID: "t1", Title: "Fix login", Labels: []taskLabel{{ID: "l1", Name: "bug", Color: "#f00"}}}
+
+	changes := diffTask(before, after)
+	if len(changes) != 1 || changes[0].Action != "label" {
+		t.Fatalf("expected a single label change, got %+v", changes)
+	}
+}
+
+func TestDeleteLabelAuditsAffectedTasks(t *testing.T) {
+	f, db := newFakeDB(t)
+	labelID := "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11"
+	bug := taskLabel{ID: labelID, Name: "bug", Color: "#f00"}
+	before := task{ID: "t1", Title: "Fix login", Status: "todo", Labels: []taskLabel{bug}, Version: 3}
+	after := before
+	after.Labels, after.Version = nil, 4
+	f.answer("FOR UPDATE", taskRowColumns, taskRow(before))
+	f.answer("DELETE FROM public.api_labels", []string{"id", "board_id", "name", "color", "created_at"},
+		[]driver.Value{labelID, "b1", "bug", "#f00", time.Now()})
+	f.answer("UPDATE public.api_tasks", taskRowColumns, taskRow(after))
+
+	s := &server{db: db}
+	r := httptest.NewRequest(http.MethodDelete, "/api/labels/"+labelID, nil)
+	r.SetPathValue("id", labelID)
+	w := httptest.NewRecorder()
+	s.labelItem(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	bumps := f.find("SET version = version + 1")
+	if len(bumps) != 1 || bumps[0].Tx == 0 {
+		t.Fatalf("expected one version bump inside the transaction, got %+v", bumps)
+	}
+	history := f.find("INSERT INTO public.api_task_history")
+	if len(history) != 1 || history[0].Args[0] != "t1" || history[0].Tx != bumps[0].Tx {
+		t.Fatalf("expected a history entry for t1 in the same transaction, got %+v", history)
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// logSchema adds the structured fields agents send with their logs: where a
+// log came from and which agent session produced it. Logs are read per agent
+// and per task, newest first.
+var logSchema = []string{
+	`ALTER TABLE public.api_logs
+		ADD COLUMN IF NOT EXISTS source TEXT,
+		ADD COLUMN IF NOT EXISTS session_id TEXT`,
+	`CREATE INDEX IF NOT EXISTS api_logs_agent_created_idx ON public.api_logs (agent_id, created_at DESC, id DESC)`,
+	`CREATE INDEX IF NOT EXISTS api_logs_task_created_idx ON public.api_logs (task_id, created_at DESC) WHERE task_id IS NOT NULL`,
+	`CREATE INDEX IF NOT EXISTS api_logs_session_idx ON public.api_logs (session_id, created_at DESC) WHERE session_id IS NOT NULL`,
+	// The inserting transaction orders rows for the log stream; see logTail.
+	`ALTER TABLE public.api_logs ADD COLUMN IF NOT EXISTS txid xid8 NOT NULL DEFAULT pg_current_xact_id()`,
+	`CREATE INDEX IF NOT EXISTS api_logs_txid_idx ON public.api_logs (txid, id)`,
+	// Levels used to be stored as sent, defaulting to info. They are
+	// upper-cased once: the new default marks the table as migrated.
+	`DO $$
+	BEGIN
+		IF (SELECT column_default FROM information_schema.columns
+			WHERE table_schema = 'public' AND table_name = 'api_logs' AND column_name = 'level')
+			IS DISTINCT FROM '''INFO''::text' THEN
+			UPDATE public.api_logs SET level = upper(level) WHERE level <> upper(level);
+			ALTER TABLE public.api_logs ALTER COLUMN level SET DEFAULT 'INFO';
+		END IF;
+	END $$`,
+}
+
+// logLevels are the accepted log levels, stored upper-cased as the frontend
+// sends and renders them.
+var logLevels = []string{"DEBUG", "INFO", "SUCCESS", "WARNING", "ERROR"}
+
+const (
+	maxLogMessageLength = 10000
+	maxLogFieldLength   = 200
+	// maxLogBytes bounds a POST /api/logs body, metadata included.
+	maxLogBytes = 256 << 10
+)
+
+// parseLogLevel normalises a level to one of logLevels; an empty level is
+// INFO.
+func parseLogLevel(v string) (string, error) {
+	level := strings.ToUpper(strings.TrimSpace(v))
+	if level == "" {
+		return "INFO", nil
+	}
+	if !slices.Contains(logLevels, level) {
+		return "", fmt.Errorf("invalid level %q, expected one of %s", v, strings.Join(logLevels, ", "))
+	}
+	return level, nil
+}
+
+// logInput is the body of POST /api/logs, matching the frontend's AgentLog.
+type logInput struct {
+	AgentID   *string         `json:"agent_id"`
+	Level     string          `json:"level"`
+	Message   string          `json:"message"`
+	Metadata  json.RawMessage `json:"metadata"`
+	Source    string          `json:"source"`
+	TaskID    string          `json:"task_id"`
+	SessionID string          `json:"session_id"`
+}
+
+// normalize validates the input in place. Metadata must be a JSON object;
+// without an explicit source, a "source" string in metadata is used.
+func (in *logInput) normalize() error {
+	var err error
+	if in.Level, err = parseLogLevel(in.Level); err != nil {
+		return err
+	}
+	in.Message = strings.TrimSpace(in.Message)
+	if in.Message == "" {
+		return errors.New("message is required")
+	}
+	if len([]rune(in.Message)) > maxLogMessageLength {
+		return fmt.Errorf("message must be at most %d characters", maxLogMessageLength)
+	}
+	in.TaskID = strings.TrimSpace(in.TaskID)
+	if in.TaskID != "" && !isUUID(in.TaskID) {
+		return errors.New("invalid task_id")
+	}
+
+	var metadata map[string]any
+	if raw := bytes.TrimSpace(in.Metadata); len(raw) > 0 && !bytes.Equal(raw, []byte("null")) {
+		if err := json.Unmarshal(raw, &metadata); err != nil || metadata == nil {
+			return errors.New("metadata must be a JSON object")
+		}
+		in.Metadata = raw
+	} else {
+		in.Metadata = nil
+	}
+	in.Source = strings.TrimSpace(in.Source)
+	if source, ok := metadata["source"].(string); ok && in.Source == "" {
+		in.Source = strings.TrimSpace(source)
+	}
+	in.SessionID = strings.TrimSpace(in.SessionID)
+	for name, v := range map[string]string{"source": in.Source, "session_id": in.SessionID, "agent_id": trimmedOrEmpty(in.AgentID)} {
+		if len(v) > maxLogFieldLength {
+			return fmt.Errorf("%s must be at most %d characters", name, maxLogFieldLength)
+		}
+	}
+	return nil
+}
+
+// logColumns is the projection shared by every query that returns a log
+// entry; keep it in sync with scanLog. JSON columns are coalesced because
+// database/sql cannot scan NULL into json.RawMessage.
+const logColumns = `id::text, agent_id, task_id::text, level, message, COALESCE(metadata, 'null'::jsonb), source, session_id, created_at`
+
+func scanLog(row rowScanner) (logEntry, error) {
+	var l logEntry
+	err := row.Scan(&l.ID, &l.AgentID, &l.TaskID, &l.Level, &l.Message, &l.Metadata, &l.Source, &l.SessionID, &l.CreatedAt)
+	l.Timestamp = l.CreatedAt
+	return l, err
+}
+
+// taskActionVerbs phrases history actions for the activity feed.
+var taskActionVerbs = map[string]string{
+	"create":  "created",
+	"delete":  "deleted",
+	"rename":  "renamed",
+	"status":  "changed the status of",
+	"move":    "moved",
+	"nest":    "regrouped",
+	"label":   "relabeled",
+	"due":     "rescheduled",
+	"archive": "archived",
+	"restore": "restored",
+	"update":  "updated",
+}
+
+// logTaskMutation writes one activity row describing a task mutation into
+// api_logs, inside the caller's transaction, so the Agent Logs page sees
+// changes made by any client.
+func logTaskMutation(ctx context.Context, tx *sql.Tx, by actor, t *task, changes []taskChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	actions := make([]string, 0, len(changes))
+	verbs := make([]string, 0, len(changes))
+	oldValues := make(map[string]any)
+	newValues := make(map[string]any)
+	for _, c := range changes {
+		actions = append(actions, c.Action)
+		verbs = append(verbs, taskActionVerbs[c.Action])
+		for k, v := range c.OldValues {
+			oldValues[k] = v
+		}
+		for k, v := range c.NewValues {
+			newValues[k] = v
+		}
+	}
+
+	message := fmt.Sprintf("%s %s task %q", by.ID, strings.Join(verbs, " and "), t.Title)
+	return insertActivityLog(ctx, tx, by, t.ID, message, map[string]any{
+		"actions": actions,
+		"old":     oldValues,
+		"new":     newValues,
+	})
+}
+
+// insertActivityLog writes an info-level api_logs row linked to taskID. The
+// actor and an "api" source are added to metadata; agent_id is only set when
+// the actor is an agent, so per-agent filters see agent activity alone.
+func insertActivityLog(ctx context.Context, tx *sql.Tx, by actor, taskID, message string, metadata map[string]any) error {
+	fields := map[string]any{"source": "api", "actor_type": by.Type, "actor_id": by.ID}
+	for k, v := range metadata {
+		fields[k] = v
+	}
+	var agentID *string
+	if by.Type == "agent" {
+		agentID = &by.ID
+	}
+	return insertLogEntry(ctx, tx, agentID, taskID, "INFO", message, fields)
+}
+
+// insertLogEntry writes one api_logs row inside the caller's transaction.
+// The "source" metadata field is also stored in the source column.
+func insertLogEntry(ctx context.Context, tx *sql.Tx, agentID *string, taskID, level, message string, metadata map[string]any) error {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	source, _ := metadata["source"].(string)
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO public.api_logs (agent_id, task_id, level, message, metadata, source)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5::jsonb, NULLIF($6, ''))`,
+		agentID, taskID, level, message, string(data), source,
+	)
+	return err
+}
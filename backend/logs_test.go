@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	for v, want := range map[string]string{"": "INFO", "info": "INFO", " Warning ": "WARNING", "SUCCESS": "SUCCESS", "error": "ERROR"} {
+		if got, err := parseLogLevel(v); err != nil || got != want {
+			t.Errorf("parseLogLevel(%q) = %q, %v; want %q", v, got, err, want)
+		}
+	}
+	for _, v := range []string{"warn", "fatal", "trace"} {
+		if _, err := parseLogLevel(v); err == nil {
+			t.Errorf("expected %q to be rejected", v)
+		}
+	}
+}
+
+func TestLogInputNormalize(t *testing.T) {
+	// The body the TrelloBoard component sends.
+	var in logInput
+	body := `{"agent_id": "user-interface", "level": "SUCCESS", "message": " moved card ", "metadata": {"source": "TrelloBoard"}}`
+	if err := json.Unmarshal([]byte(body), &in); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.normalize(); err != nil {
+		t.Fatal(err)
+	}
+	if in.Level != "SUCCESS" || in.Message != "moved card" || in.Source != "TrelloBoard" {
+		t.Fatalf("unexpected normalised input %+v", in)
+	}
+
+	explicit := logInput{Message: "x", Source: "cli", Metadata: json.RawMessage(`{"source": "other"}`)}
+	if err := explicit.normalize(); err != nil || explicit.Source != "cli" {
+		t.Fatalf("expected an explicit source to win, got %q %v", explicit.Source, err)
+	}
+	empty := logInput{Message: "x", Metadata: json.RawMessage(`null`)}
+	if err := empty.normalize(); err != nil || empty.Metadata != nil {
+		t.Fatalf("expected null metadata to be dropped, got %s %v", empty.Metadata, err)
+	}
+}
+
+func TestLogInputNormalizeValidates(t *testing.T) {
+	cases := []logInput{
+		{Message: " "},
+		{Message: "x", Level: "loud"},
+		{Message: "x", TaskID: "not-a-uuid"},
+		{Message: "x", Metadata: json.RawMessage(`[1, 2]`)},
+		{Message: "x", SessionID: strings.Repeat("s", maxLogFieldLength+1)},
+	}
+	for i, in := range cases {
+		if err := in.normalize(); err == nil {
+			t.Errorf("case %d: expected a validation error", i)
+		}
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	where, err := logFilter(url.Values{"level": {"WARNING,error"}, "source": {"sweeper"}, "session_id": {"s1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := where.sql(); !strings.Contains(got, "level = ANY($1)") || !strings.Contains(got, "session_id = ANY($3)") {
+		t.Fatalf("unexpected filter %q", got)
+	}
+	if levels := where.args[0].([]string); levels[0] != "WARNING" || levels[1] != "ERROR" {
+		t.Fatalf("expected levels to be normalised, got %v", levels)
+	}
+	if _, err := logFilter(url.Values{"task_id": {"nope"}}); err == nil {
+		t.Fatal("expected an invalid task id to be rejected")
+	}
+}
+
+func TestInsertActivityLogAgentID(t *testing.T) {
+	cases := []struct {
+		by    actor
+		agent string
+	}{
+		{actor{Type: "agent", ID: "arga"}, "arga"},
+		{actor{Type: "user", ID: "martha"}, ""},
+		{anonymousActor, ""},
+		{sweeperActor, ""},
+	}
+	for _, c := range cases {
+		f, db := newFakeDB(t)
+		ctx := context.Background()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := insertActivityLog(ctx, tx, c.by, "", "Task updated", nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+
+		stmts := f.find("INSERT INTO public.api_logs")
+		if len(stmts) != 1 {
+			t.Fatalf("%v: got %d log inserts, want 1", c.by, len(stmts))
+		}
+		s := stmts[0]
+		if s.Tx == 0 {
+			t.Errorf("%v: log row written outside the transaction", c.by)
+		}
+		var agent string
+		if p, _ := s.Args[0].(*string); p != nil {
+			agent = *p
+		}
+		if agent != c.agent {
+			t.Errorf("%v: agent_id = %q, want %q", c.by, agent, c.agent)
+		}
+		var metadata map[string]any
+		if err := json.Unmarshal([]byte(s.Args[4].(string)), &metadata); err != nil {
+			t.Fatal(err)
+		}
+		if metadata["actor_id"] != c.by.ID || metadata["actor_type"] != c.by.Type {
+			t.Errorf("%v: metadata = %v", c.by, metadata)
+		}
+	}
+}
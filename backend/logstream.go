@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	logStreamPollInterval = time.Second
+	// logStreamFallbackInterval applies while the realtime hub is listening
+	// and polls mostly follow its insert notifications.
+	logStreamFallbackInterval = 15 * time.Second
+	logStreamKeepalive        = 15 * time.Second
+	// logStreamDebounce is the least time between polls woken by inserts.
+	logStreamDebounce = 250 * time.Millisecond
+	logStreamBatch    = 200
+	// logStreamWriteTimeout drops clients that stop reading; they resume
+	// from their Last-Event-ID when they reconnect.
+	logStreamWriteTimeout = 10 * time.Second
+	logStreamRetry        = 3 * time.Second
+)
+
+// sseEvent is one Server-Sent Events message; Data is sent as JSON.
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  any
+}
+
+func writeSSE(w io.Writer, e sseEvent) error {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Event)
+	}
+	// encoding/json escapes newlines, so the payload is a single data line.
+	fmt.Fprintf(&b, "data: %s\n\n", data)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// sseWriter sends to one client. Every write gets a deadline, so a client
+// that stops reading is dropped instead of holding the handler.
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	return &sseWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+func (sw *sseWriter) send(write func(io.Writer) error) error {
+	if err := sw.rc.SetWriteDeadline(time.Now().Add(logStreamWriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	if err := write(sw.w); err != nil {
+		return err
+	}
+	return sw.rc.Flush()
+}
+
+// streamCursor is a position in the log stream. Rows are streamed in the
+// order of the transaction that inserted them, then by id.
+type streamCursor struct {
+	TxID uint64 `json:"x"`
+	ID   string `json:"id"`
+}
+
+// minCursorID sorts before every UUID, so a cursor using it covers no rows
+// of its transaction.
+const minCursorID = "00000000-0000-0000-0000-000000000000"
+
+func encodeStreamCursor(c streamCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeStreamCursor(v string) (streamCursor, error) {
+	var c streamCursor
+	data, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.TxID == 0 || !isUUID(c.ID) {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// lastEventID reads the position a client resumes from: the Last-Event-ID
+// header sent by EventSource on reconnect, or ?last_event_id= for the first
+// connection.
+func lastEventID(r *http.Request) (*streamCursor, error) {
+	v := strings.TrimSpace(r.Header.Get("Last-Event-ID"))
+	if v == "" {
+		v = strings.TrimSpace(r.URL.Query().Get("last_event_id"))
+	}
+	if v == "" {
+		return nil, nil
+	}
+	c, err := decodeStreamCursor(v)
+	if err != nil {
+		return nil, errors.New("invalid Last-Event-ID")
+	}
+	return &c, nil
+}
+
+// logTail follows api_logs for one stream from cursor, the last position
+// sent. It only reads rows inserted by transactions older than every one
+// still running, so a row committing after newer ones can never be passed
+// over; a long transaction holds the stream back until it ends.
+type logTail struct {
+	q      url.Values
+	cursor streamCursor
+}
+
+func newLogTail(q url.Values, cursor streamCursor) (*logTail, error) {
+	if err := addLogConditions(&whereBuilder{}, q); err != nil {
+		return nil, err
+	}
+	return &logTail{q: q, cursor: cursor}, nil
+}
+
+// where builds the stream's filters for the rows after the cursor that are
+// below the watermark.
+func (t *logTail) where() *whereBuilder {
+	where := &whereBuilder{}
+	// The filters were validated by newLogTail.
+	_ = addLogConditions(where, t.q)
+	where.add("txid < pg_snapshot_xmin(pg_current_snapshot())")
+	where.add(fmt.Sprintf("(txid, id) > (%s::text::xid8, %s::uuid)",
+		where.arg(strconv.FormatUint(t.cursor.TxID, 10)), where.arg(t.cursor.ID)))
+	return where
+}
+
+// txidScanner scans a row of logColumns followed by txid.
+type txidScanner struct {
+	rows *sql.Rows
+	txid *uint64
+}
+
+func (s txidScanner) Scan(dest ...any) error {
+	return s.rows.Scan(append(dest, s.txid)...)
+}
+
+// next returns up to logStreamBatch rows after the cursor, in stream order.
+// Each event carries the cursor after its row, so a client resuming from it
+// skips nothing and gets nothing twice.
+func (t *logTail) next(ctx context.Context, q queryer) ([]sseEvent, error) {
+	where := t.where()
+	var events []sseEvent
+	err := eachRow(ctx, q, func(rows *sql.Rows) error {
+		var txid uint64
+		l, err := scanLog(txidScanner{rows, &txid})
+		if err != nil {
+			return err
+		}
+		t.cursor = streamCursor{TxID: txid, ID: l.ID}
+		events = append(events, sseEvent{ID: encodeStreamCursor(t.cursor), Event: "log", Data: l})
+		return nil
+	}, `SELECT `+logColumns+`, txid::text
+		FROM public.api_logs
+		`+where.sql()+`
+		ORDER BY txid, id
+		LIMIT `+where.arg(logStreamBatch), where.args...)
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// streamLogs serves GET /api/logs/stream, pushing new api_logs rows as
+// Server-Sent Events. It accepts the filters of GET /api/logs and resumes
+// from Last-Event-ID; without one it starts at the current time. A client
+// that reads too slowly is disconnected and catches up from the database on
+// reconnect, so nothing is buffered per connection beyond one batch.
+func (s *server) streamLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	resume, err := lastEventID(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	var cursor streamCursor
+	if resume != nil {
+		cursor = *resume
+	}
+	tail, err := newLogTail(r.URL.Query(), cursor)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if resume == nil {
+		// Start at the watermark: rows of transactions still running are
+		// sent once they commit.
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		tail.cursor.ID = minCursorID
+		err = s.db.QueryRowContext(ctx, `SELECT pg_snapshot_xmin(pg_current_snapshot())::text`).Scan(&tail.cursor.TxID)
+		cancel()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// Keeps nginx from buffering the stream.
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	sw := newSSEWriter(w)
+	if err := sw.send(func(w io.Writer) error {
+		_, err := fmt.Fprintf(w, "retry: %d\n\n", logStreamRetry.Milliseconds())
+		return err
+	}); err != nil {
+		return
+	}
+
+	// Inserts announced by the realtime hub trigger a poll right away.
+	var wake <-chan changeEvent
+	var resubscribe func()
+	if s.hub != nil {
+		sub := s.hub.subscribe("logs")
+		defer func() { sub.Close() }()
+		wake = sub.C
+		resubscribe = func() {
+			sub = s.hub.subscribe("logs")
+			wake = sub.C
+		}
+	}
+
+	// due is when the poll timer fires and polled when the last poll ran;
+	// a burst of inserts wakes the stream at most once per
+	// logStreamDebounce.
+	poll := time.NewTimer(0)
+	defer poll.Stop()
+	due := time.Now()
+	var polled time.Time
+	schedule := func(d time.Duration) {
+		poll.Reset(d)
+		due = time.Now().Add(d)
+	}
+	keepalive := time.NewTicker(logStreamKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.closing:
+			return
+		case _, ok := <-wake:
+			if !ok {
+				// Dropped for falling behind; the poll catches up anyway.
+				resubscribe()
+			}
+			if at := polled.Add(logStreamDebounce); at.Before(due) {
+				schedule(time.Until(at))
+			}
+		case <-keepalive.C:
+			if err := sw.send(func(w io.Writer) error {
+				_, err := io.WriteString(w, ": keepalive\n\n")
+				return err
+			}); err != nil {
+				return
+			}
+		case <-poll.C:
+			polled = time.Now()
+			ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+			events, err := tail.next(ctx, s.db)
+			cancel()
+			if err != nil {
+				if r.Context().Err() != nil {
+					return
+				}
+				log.Printf("log stream poll failed: %v", err)
+			}
+			if len(events) > 0 {
+				if err := sw.send(func(w io.Writer) error {
+					for _, e := range events {
+						if err := writeSSE(w, e); err != nil {
+							return err
+						}
+					}
+					return nil
+				}); err != nil {
+					return
+				}
+				keepalive.Reset(logStreamKeepalive)
+			}
+			// A full batch means the client is behind; fetch the rest now.
+			if len(events) == logStreamBatch {
+				schedule(0)
+			} else {
+				schedule(s.logStreamInterval())
+			}
+		}
+	}
+}
+
+// logStreamInterval is the time between polls of a log stream. While the
+// realtime hub is listening, streams poll when it announces an insert and
+// only rarely otherwise.
+func (s *server) logStreamInterval() time.Duration {
+	if s.hub != nil && s.hub.listening.Load() {
+		return logStreamFallbackInterval
+	}
+	return logStreamPollInterval
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSSE(t *testing.T) {
+	var b strings.Builder
+	if err := writeSSE(&b, sseEvent{ID: "c1", Event: "log", Data: map[string]string{"message": "two\nlines"}}); err != nil {
+		t.Fatal(err)
+	}
+	want := "id: c1\nevent: log\ndata: {\"message\":\"two\\nlines\"}\n\n"
+	if b.String() != want {
+		t.Fatalf("expected %q, got %q", want, b.String())
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	c := streamCursor{TxID: 1234, ID: "00000000-0000-0000-0000-000000000001"}
+
+	r := httptest.NewRequest(http.MethodGet, "/api/logs/stream?last_event_id="+encodeStreamCursor(c), nil)
+	got, err := lastEventID(r)
+	if err != nil || got == nil || *got != c {
+		t.Fatalf("expected the query parameter to be read, got %v %v", got, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/logs/stream?last_event_id=ignored", nil)
+	r.Header.Set("Last-Event-ID", encodeStreamCursor(c))
+	if got, err := lastEventID(r); err != nil || got == nil || *got != c {
+		t.Fatalf("expected the header to win, got %v %v", got, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api/logs/stream", nil)
+	if got, err := lastEventID(r); err != nil || got != nil {
+		t.Fatalf("expected no cursor, got %v %v", got, err)
+	}
+	for _, v := range []string{"garbage", encodeCursor(pageCursor{CreatedAt: time.Now(), ID: c.ID})} {
+		r.Header.Set("Last-Event-ID", v)
+		if _, err := lastEventID(r); err == nil {
+			t.Fatalf("expected Last-Event-ID %q to be rejected", v)
+		}
+	}
+}
+
+func TestLogTailFollowsWatermark(t *testing.T) {
+	f, db := newFakeDB(t)
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	f.answer("FROM public.api_logs",
+		[]string{"id", "agent_id", "task_id", "level", "message", "metadata", "source", "session_id", "created_at", "txid"},
+		[]driver.Value{"00000000-0000-0000-0000-000000000002", nil, nil, "ERROR", "first", []byte("null"), nil, nil, at, "101"},
+		// Committed after the first row although it started earlier.
+		[]driver.Value{"00000000-0000-0000-0000-000000000001", nil, nil, "ERROR", "second", []byte("null"), nil, nil, at.Add(-time.Minute), "105"},
+	)
+
+	tail, err := newLogTail(url.Values{"level": {"error"}}, streamCursor{TxID: 100, ID: minCursorID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := tail.next(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := streamCursor{TxID: 105, ID: "00000000-0000-0000-0000-000000000001"}
+	if len(events) != 2 || tail.cursor != want || events[1].ID != encodeStreamCursor(want) {
+		t.Fatalf("expected the cursor to follow the rows, got %+v after %d events", tail.cursor, len(events))
+	}
+
+	polls := f.find("FROM public.api_logs")
+	if len(polls) != 1 {
+		t.Fatalf("expected one poll, got %d", len(polls))
+	}
+	q := polls[0].Query
+	if !strings.Contains(q, "txid < pg_snapshot_xmin(pg_current_snapshot())") || !strings.Contains(q, "(txid, id) > ($2::text::xid8, $3::uuid)") {
+		t.Fatalf("expected the poll to stop at the watermark and resume after the cursor, got %q", q)
+	}
+	if len(polls[0].Args) != 4 || polls[0].Args[1] != "100" {
+		t.Fatalf("expected only the filter, cursor and limit as arguments, got %v", polls[0].Args)
+	}
+}
+
+func TestNewLogTailRejectsInvalidFilters(t *testing.T) {
+	if _, err := newLogTail(url.Values{"level": {"loud"}}, streamCursor{}); err == nil {
+		t.Fatal("expected an invalid level to be rejected")
+	}
+}
+
+func TestStreamLogsRequiresDatabase(t *testing.T) {
+	s := &server{}
+	w := httptest.NewRecorder()
+	s.streamLogs(w, httptest.NewRequest(http.MethodGet, "/api/logs/stream", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.streamLogs(w, httptest.NewRequest(http.MethodPost, "/api/logs/stream", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status 405, got %d", w.Code)
+	}
+}
+
+func TestStreamingOverridesJSONContentType(t *testing.T) {
+	h := withJSONContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/logs/stream", nil))
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected the stream's content type, got %q", got)
+	}
+}
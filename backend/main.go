@@ -10,7 +10,9 @@ import (
 	"log"
 	"math"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -18,6 +20,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
@@ -27,25 +30,60 @@ var allowedOrigins = map[string]struct{}{
 }
 
 type server struct {
-	db *sql.DB
+	db       *sql.DB
+	workflow *workflow
+	leaseTTL time.Duration
+	hub      *realtimeHub
+	sockets  *wsHub
+	// closing is closed when the server starts shutting down, ending log
+	// streams and WebSockets that would otherwise hold it up.
+	closing chan struct{}
 }
 
 type task struct {
-	ID        string    `json:"id"`
-	Title     string    `json:"title"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          string        `json:"id"`
+	ColumnID    *string       `json:"column_id"`
+	ParentID    *string       `json:"parent_id"`
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	Status      string        `json:"status"`
+	Order       int           `json:"order"`
+	Rank        *string       `json:"rank"`
+	AssignedTo  *string       `json:"assigned_to"`
+	DueAt       *time.Time    `json:"due_at"`
+	OverdueAt   *time.Time    `json:"overdue_at"`
+	ArchivedAt  *time.Time    `json:"archived_at"`
+	Labels      []taskLabel   `json:"labels"`
+	Progress    *taskProgress `json:"progress,omitempty"`
+	TimeSpent   *timeSpent    `json:"time_spent,omitempty"`
+	Version     int           `json:"version"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
 }
 
+// logEntry is an api_logs row. Timestamp repeats CreatedAt under the name
+// the frontend's AgentLog type uses.
 type logEntry struct {
-	ID        string    `json:"id"`
-	Level     string    `json:"level"`
-	Message   string    `json:"message"`
-	CreatedAt time.Time `json:"created_at"`
+	ID        string          `json:"id"`
+	AgentID   *string         `json:"agent_id"`
+	TaskID    *string         `json:"task_id"`
+	Level     string          `json:"level"`
+	Message   string          `json:"message"`
+	Metadata  json.RawMessage `json:"metadata"`
+	Source    *string         `json:"source"`
+	SessionID *string         `json:"session_id"`
+	CreatedAt time.Time       `json:"created_at"`
+	Timestamp time.Time       `json:"timestamp"`
 }
 
 func main() {
+	// Cancelled on SIGINT or SIGTERM: background workers stop, open streams
+	// end and in-flight requests drain before the process exits.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	var db *sql.DB
+	var hub *realtimeHub
 	if dsn, err := databaseURL(); err != nil {
 		// Some environments (like lightweight VPS deployments) may run read-only
 		// dashboard endpoints without a database configured.
@@ -55,26 +93,89 @@ func main() {
 		if err != nil {
 			log.Fatalf("open db: %v", err)
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		setupCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 
-		if err := opened.PingContext(ctx); err != nil {
+		if err := opened.PingContext(setupCtx); err != nil {
 			opened.Close()
 			log.Printf("db ping failed (continuing without db): %v", err)
-		} else if err := ensureSchema(ctx, opened); err != nil {
+		} else if err := ensureSchema(setupCtx, opened); err != nil {
 			opened.Close()
 			log.Printf("ensure schema failed (continuing without db): %v", err)
 		} else {
 			db = opened
 			defer db.Close()
+			// LISTEN needs a session, which transaction-mode poolers such as
+			// Supabase's port 6543 do not provide.
+			hub = newRealtimeHub(envOrDefault("REALTIME_DATABASE_URL", dsn))
 		}
 	}
 
-	s := &server{db: db}
+	wf, err := loadWorkflow(strings.TrimSpace(os.Getenv("TASK_WORKFLOW_FILE")))
+	if err != nil {
+		log.Fatalf("load workflow: %v", err)
+	}
+
+	sweep, err := loadSweepConfig()
+	if err != nil {
+		log.Fatalf("load sweep config: %v", err)
+	}
+
+	leaseTTL, err := loadLeaseTTL()
+	if err != nil {
+		log.Fatalf("load lease ttl: %v", err)
+	}
+
+	s := &server{db: db, workflow: wf, leaseTTL: leaseTTL, hub: hub, sockets: newWSHub(), closing: make(chan struct{})}
+	if db != nil {
+		go s.runSweeper(ctx, sweep)
+		go hub.run(ctx)
+		go s.routeRealtime(ctx)
+	}
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", s.health)
 	mux.HandleFunc("/api/tasks", s.tasks)
+	mux.HandleFunc("/api/tasks/bulk", s.bulkTasks)
+	mux.HandleFunc("/api/tasks/claim", s.claimTask)
+	mux.HandleFunc("/api/tasks/{id}", s.taskItem)
+	mux.HandleFunc("/api/tasks/{id}/move", s.moveTask)
+	mux.HandleFunc("/api/tasks/{id}/restore", s.restoreTask)
+	mux.HandleFunc("/api/tasks/{id}/history", s.taskHistory)
+	mux.HandleFunc("/api/tasks/{id}/comments", s.taskComments)
+	mux.HandleFunc("/api/tasks/{id}/comments/{commentID}", s.taskComment)
+	mux.HandleFunc("/api/tasks/{id}/labels", s.taskLabels)
+	mux.HandleFunc("/api/tasks/{id}/dependencies", s.taskDependencies)
+	mux.HandleFunc("/api/tasks/{id}/dependencies/{blockerID}", s.taskDependency)
+	mux.HandleFunc("/api/tasks/{id}/checklist", s.taskChecklist)
+	mux.HandleFunc("/api/tasks/{id}/checklist/{itemID}", s.taskChecklistItem)
+	mux.HandleFunc("/api/tasks/{id}/lease", s.taskLease)
+	mux.HandleFunc("/api/tasks/{id}/lease/heartbeat", s.heartbeatLease)
+	mux.HandleFunc("/api/tasks/{id}/time", s.taskTime)
+	mux.HandleFunc("/api/tasks/{id}/time/{entryID}", s.taskTimeEntry)
+	mux.HandleFunc("/api/tasks/{id}/timer/start", s.startTimer)
+	mux.HandleFunc("/api/tasks/{id}/timer/stop", s.stopTimer)
+	mux.HandleFunc("/api/tasks/{id}/labels/{labelID}", s.taskLabel)
+	mux.HandleFunc("/api/boards", s.boards)
+	mux.HandleFunc("/api/boards/import", s.importBoard)
+	mux.HandleFunc("/api/boards/{id}", s.boardItem)
+	mux.HandleFunc("/api/boards/{id}/export", s.exportBoard)
+	mux.HandleFunc("/api/boards/{id}/columns", s.boardColumns)
+	mux.HandleFunc("/api/boards/{id}/labels", s.boardLabels)
+	mux.HandleFunc("/api/boards/{id}/templates", s.boardTemplates)
+	mux.HandleFunc("/api/columns/{id}", s.columnItem)
+	mux.HandleFunc("/api/columns/{id}/tasks", s.columnTasks)
+	mux.HandleFunc("/api/columns/{id}/restore", s.restoreColumn)
+	mux.HandleFunc("/api/labels/{id}", s.labelItem)
+	mux.HandleFunc("/api/templates/{id}", s.templateItem)
+	mux.HandleFunc("/api/templates/{id}/preview", s.previewTemplate)
+	mux.HandleFunc("/api/templates/{id}/instantiate", s.instantiateTemplateNow)
+	mux.HandleFunc("/api/workflow", s.workflowInfo)
+	mux.HandleFunc("/api/search", s.search)
+	mux.HandleFunc("/api/archive", s.listArchive)
+	mux.HandleFunc("/api/time/report", s.timeReport)
 	mux.HandleFunc("/api/logs", s.logs)
+	mux.HandleFunc("/api/logs/stream", s.streamLogs)
+	mux.HandleFunc("/api/ws", s.webSocket)
 	mux.HandleFunc("/api/token-usage", s.tokenUsage)
 	mux.HandleFunc("/api/vps", s.vps)
 	mux.HandleFunc("/api/news", s.news)
@@ -83,12 +184,29 @@ func main() {
 	handler := withCORS(withJSONContentType(mux))
 
 	addr := envOrDefault("BIND_ADDR", "127.0.0.1:8080")
+	srv := &http.Server{Addr: addr, Handler: handler}
+	srv.RegisterOnShutdown(func() { close(s.closing) })
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
 	log.Printf("heista-go listening on %s", addr)
-	if err := http.ListenAndServe(addr, handler); err != nil {
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
 		log.Fatalf("listen: %v", err)
 	}
+	<-drained
+	log.Printf("heista-go stopped")
 }
 
+// withJSONContentType defaults every response to JSON. Streaming handlers
+// such as /api/logs/stream replace the header before writing.
 func withJSONContentType(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -102,8 +220,9 @@ func withCORS(next http.Handler) http.Handler {
 		if _, ok := allowedOrigins[origin]; ok {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Vary", "Origin")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, X-Agent-ID, X-User-ID, Last-Event-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "ETag, Content-Disposition")
 		}
 
 		if r.Method == http.MethodOptions {
@@ -276,14 +395,26 @@ func (s *server) listTasks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	where, err := taskFilter(r.URL.Query(), s.taskWorkflow())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id::text, title, status, created_at
+		SELECT `+taskColumns+`
 		FROM public.api_tasks
-		ORDER BY created_at DESC
-		LIMIT 100`)
+		`+where.sql()+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+where.arg(limit+1), where.args...)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -292,8 +423,8 @@ func (s *server) listTasks(w http.ResponseWriter, r *http.Request) {
 
 	items := make([]task, 0)
 	for rows.Next() {
-		var t task
-		if err := rows.Scan(&t.ID, &t.Title, &t.Status, &t.CreatedAt); err != nil {
+		t, err := scanTask(rows)
+		if err != nil {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
@@ -303,8 +434,18 @@ func (s *server) listTasks(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	if err := attachProgress(ctx, s.db, s.taskWorkflow(), taskPointers(items)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := attachTimeSpent(ctx, s.db, taskPointers(items)); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
 
-	writeJSON(w, http.StatusOK, items)
+	writeJSON(w, http.StatusOK, newPage(items, limit, func(t task) pageCursor {
+		return pageCursor{CreatedAt: t.CreatedAt, ID: t.ID}
+	}))
 }
 
 func (s *server) createTask(w http.ResponseWriter, r *http.Request) {
@@ -314,36 +455,118 @@ func (s *server) createTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var in struct {
-		Title  string `json:"title"`
-		Status string `json:"status"`
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+		Status      string  `json:"status"`
+		ColumnID    *string `json:"column_id"`
+		ParentID    string  `json:"parent_id"`
+		Order       *int    `json:"order"`
+		AssignedTo  *string `json:"assigned_to"`
+		DueAt       string  `json:"due_at"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
 		return
 	}
+	// Tasks created through /api/columns/{id}/tasks are scoped to that column.
+	if columnID := r.PathValue("id"); columnID != "" {
+		in.ColumnID = &columnID
+	}
 	in.Title = strings.TrimSpace(in.Title)
+	in.Description = strings.TrimSpace(in.Description)
 	in.Status = strings.TrimSpace(in.Status)
+	if in.ColumnID != nil && !isUUID(*in.ColumnID) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid column_id"})
+		return
+	}
+	in.ParentID = strings.TrimSpace(in.ParentID)
+	if in.ParentID != "" && !isUUID(in.ParentID) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid parent_id"})
+		return
+	}
 	if in.Title == "" {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "title is required"})
 		return
 	}
+	dueAt, err := parseDueAt(in.DueAt)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	wf := s.taskWorkflow()
 	if in.Status == "" {
-		in.Status = "todo"
+		in.Status = wf.Initial
+	}
+	if err := wf.checkState(in.Status); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	override, err := overrideWIPParam(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	var out task
-	err := s.db.QueryRowContext(ctx, `
-		INSERT INTO public.api_tasks (title, status)
-		VALUES ($1, $2)
-		RETURNING id::text, title, status, created_at`, in.Title, in.Status,
-	).Scan(&out.ID, &out.Title, &out.Status, &out.CreatedAt)
+	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	defer tx.Rollback()
+
+	if in.ParentID != "" {
+		if err := checkParent(ctx, tx, "", in.ParentID); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_tasks (title, description, status, "order", assigned_to, due_at, parent_id)
+		VALUES ($1, $2, $3, COALESCE($4, 0), NULLIF($5, ''), $6, NULLIF($7, '')::uuid)
+		RETURNING id::text`, in.Title, in.Description, in.Status, in.Order, trimmedOrEmpty(in.AssignedTo), dueAt, in.ParentID,
+	).Scan(&id)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	// Ranked placement treats the requested order as a position in the column.
+	if in.ColumnID != nil {
+		created, err := lockTask(ctx, tx, id, *in.ColumnID)
+		if err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		if err := placeTask(ctx, tx, created, *in.ColumnID, placement{Index: in.Order}); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+	out, err := scanTask(tx.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	by := actorFromRequest(r)
+	if err := enforceWIP(ctx, tx, by, nil, out, override); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := auditTask(ctx, tx, by, nil, &out); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
 
 	writeJSON(w, http.StatusCreated, out)
 }
@@ -354,14 +577,26 @@ func (s *server) listLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	where, err := logFilter(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT id::text, level, message, created_at
+		SELECT `+logColumns+`
 		FROM public.api_logs
-		ORDER BY created_at DESC
-		LIMIT 100`)
+		`+where.sql()+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+where.arg(limit+1), where.args...)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -370,8 +605,8 @@ func (s *server) listLogs(w http.ResponseWriter, r *http.Request) {
 
 	items := make([]logEntry, 0)
 	for rows.Next() {
-		var item logEntry
-		if err := rows.Scan(&item.ID, &item.Level, &item.Message, &item.CreatedAt); err != nil {
+		item, err := scanLog(rows)
+		if err != nil {
 			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 			return
 		}
@@ -382,7 +617,54 @@ func (s *server) listLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, items)
+	writeJSON(w, http.StatusOK, newPage(items, limit, func(l logEntry) pageCursor {
+		return pageCursor{CreatedAt: l.CreatedAt, ID: l.ID}
+	}))
+}
+
+// logFilter translates the query string of GET /api/logs into SQL conditions.
+func logFilter(q url.Values) (*whereBuilder, error) {
+	where := &whereBuilder{}
+	if err := addLogConditions(where, q); err != nil {
+		return nil, err
+	}
+	if err := where.addPageFilters(q, "created_at", "id"); err != nil {
+		return nil, err
+	}
+	return where, nil
+}
+
+// addLogConditions adds the level, agent, source, session and task filters
+// shared by the log listing and the log stream.
+func addLogConditions(where *whereBuilder, q url.Values) error {
+	if levels := listParam(q, "level"); len(levels) > 0 {
+		for i, v := range levels {
+			level, err := parseLogLevel(v)
+			if err != nil {
+				return err
+			}
+			levels[i] = level
+		}
+		where.add("level = ANY(" + where.arg(levels) + ")")
+	}
+	if agents := listParam(q, "agent", "agent_id"); len(agents) > 0 {
+		where.add("agent_id = ANY(" + where.arg(agents) + ")")
+	}
+	if sources := listParam(q, "source"); len(sources) > 0 {
+		where.add("source = ANY(" + where.arg(sources) + ")")
+	}
+	if sessions := listParam(q, "session", "session_id"); len(sessions) > 0 {
+		where.add("session_id = ANY(" + where.arg(sessions) + ")")
+	}
+	if tasks := listParam(q, "task", "task_id"); len(tasks) > 0 {
+		for _, id := range tasks {
+			if !isUUID(id) {
+				return fmt.Errorf("invalid task id %q", id)
+			}
+		}
+		where.add("task_id = ANY(" + where.arg(tasks) + "::uuid[])")
+	}
+	return nil
 }
 
 func (s *server) createLog(w http.ResponseWriter, r *http.Request) {
@@ -391,33 +673,29 @@ func (s *server) createLog(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var in struct {
-		Level   string `json:"level"`
-		Message string `json:"message"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+	var in logInput
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLogBytes)).Decode(&in); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
 		return
 	}
-	in.Level = strings.TrimSpace(in.Level)
-	in.Message = strings.TrimSpace(in.Message)
-	if in.Message == "" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "message is required"})
+	if err := in.normalize(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	if in.Level == "" {
-		in.Level = "info"
+	// Agents that identify themselves with X-Agent-ID need not repeat it.
+	agentID := trimmedOrEmpty(in.AgentID)
+	if by := actorFromRequest(r); agentID == "" && by.Type == "agent" {
+		agentID = by.ID
 	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
 	defer cancel()
 
-	var out logEntry
-	err := s.db.QueryRowContext(ctx, `
-		INSERT INTO public.api_logs (level, message)
-		VALUES ($1, $2)
-		RETURNING id::text, level, message, created_at`, in.Level, in.Message,
-	).Scan(&out.ID, &out.Level, &out.Message, &out.CreatedAt)
+	out, err := scanLog(s.db.QueryRowContext(ctx, `
+		INSERT INTO public.api_logs (agent_id, level, message, metadata, source, task_id, session_id)
+		VALUES (NULLIF($1, ''), $2, $3, $4::jsonb, NULLIF($5, ''), NULLIF($6, '')::uuid, NULLIF($7, ''))
+		RETURNING `+logColumns, agentID, in.Level, in.Message, nullableString(string(in.Metadata)), in.Source, in.TaskID, in.SessionID,
+	))
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -435,13 +713,42 @@ func ensureSchema(ctx context.Context, db *sql.DB) error {
 			status TEXT NOT NULL DEFAULT 'todo',
 			created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
 		)`,
+		`ALTER TABLE public.api_tasks
+			ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+			ADD COLUMN IF NOT EXISTS column_id UUID,
+			ADD COLUMN IF NOT EXISTS "order" INTEGER NOT NULL DEFAULT 0,
+			ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())`,
+		`ALTER TABLE public.api_tasks ADD COLUMN IF NOT EXISTS assigned_to TEXT`,
+		`ALTER TABLE public.api_tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1`,
 		`CREATE TABLE IF NOT EXISTS public.api_logs (
 			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
 			level TEXT NOT NULL DEFAULT 'info',
 			message TEXT NOT NULL,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
 		)`,
-	}
+		`ALTER TABLE public.api_logs ADD COLUMN IF NOT EXISTS agent_id TEXT`,
+		`ALTER TABLE public.api_logs
+			ADD COLUMN IF NOT EXISTS task_id UUID,
+			ADD COLUMN IF NOT EXISTS metadata JSONB`,
+		`CREATE INDEX IF NOT EXISTS api_tasks_created_idx ON public.api_tasks (created_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS api_logs_created_idx ON public.api_logs (created_at DESC, id DESC)`,
+	}
+	queries = append(queries, boardSchema...)
+	queries = append(queries, moveSchema...)
+	queries = append(queries, historySchema...)
+	queries = append(queries, commentSchema...)
+	queries = append(queries, labelSchema...)
+	queries = append(queries, dueSchema...)
+	queries = append(queries, claimSchema...)
+	queries = append(queries, searchSchema...)
+	queries = append(queries, dependencySchema...)
+	queries = append(queries, checklistSchema...)
+	queries = append(queries, templateSchema...)
+	queries = append(queries, archiveSchema...)
+	queries = append(queries, timeSchema...)
+	queries = append(queries, wipSchema...)
+	queries = append(queries, logSchema...)
+	queries = append(queries, realtimeSchema...)
 	for _, q := range queries {
 		if _, err := db.ExecContext(ctx, q); err != nil {
 			return err
@@ -709,6 +1016,55 @@ func sumTokensInJSONL(path string, since time.Time) (int, bool, error) {
 	return tokens, counted, nil
 }
 
+// statusError lets helpers that run inside a transaction fail with a specific
+// HTTP status and payload; writeStoreError renders it as-is.
+type statusError struct {
+	status int
+	body   map[string]any
+}
+
+func newStatusError(status int, message string) *statusError {
+	return &statusError{status: status, body: map[string]any{"error": message}}
+}
+
+func (e *statusError) Error() string {
+	if msg, ok := e.body["error"].(string); ok {
+		return msg
+	}
+	return http.StatusText(e.status)
+}
+
+// writeStoreError maps database errors onto HTTP statuses so handlers can
+// report missing rows and constraint violations consistently.
+func writeStoreError(w http.ResponseWriter, err error, notFound string) {
+	status, body := storeErrorResponse(err, notFound)
+	writeJSON(w, status, body)
+}
+
+// storeErrorResponse is the status and payload writeStoreError renders for
+// err; endpoints reporting several outcomes at once use it directly.
+func storeErrorResponse(err error, notFound string) (int, any) {
+	var pgErr *pgconn.PgError
+	var statusErr *statusError
+	switch {
+	case errors.As(err, &statusErr):
+		return statusErr.status, statusErr.body
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound, map[string]string{"error": notFound}
+	case errors.As(err, &pgErr) && (pgErr.Code == "23505" || pgErr.Code == "23503"):
+		// unique_violation / foreign_key_violation
+		return http.StatusConflict, map[string]string{"error": pgErr.Message}
+	case errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01"):
+		// serialization_failure / deadlock_detected: safe for the client to retry
+		return http.StatusConflict, map[string]string{"error": "concurrent update, please retry"}
+	case errors.As(err, &pgErr) && pgErr.Code == "22P02":
+		// invalid_text_representation, e.g. a malformed UUID
+		return http.StatusBadRequest, map[string]string{"error": pgErr.Message}
+	default:
+		return http.StatusInternalServerError, map[string]string{"error": err.Error()}
+	}
+}
+
 func writeJSON(w http.ResponseWriter, status int, payload any) {
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
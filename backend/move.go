@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// placement says where a task should land inside its target column. At most
+// one of BeforeID, AfterID and Index is set; none of them means "append".
+type placement struct {
+	BeforeID string
+	AfterID  string
+	Index    *int
+}
+
+// moveSchema adds the lexicographic rank used to order tasks within a column.
+var moveSchema = []string{
+	`ALTER TABLE public.api_tasks ADD COLUMN IF NOT EXISTS rank TEXT COLLATE "C"`,
+	`CREATE INDEX IF NOT EXISTS api_tasks_column_rank_idx ON public.api_tasks (column_id, rank)`,
+}
+
+type rankedTask struct {
+	id   string
+	rank sql.NullString
+}
+
+func (s *server) moveTask(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	var in struct {
+		ColumnID string `json:"column_id"`
+		BeforeID string `json:"before_id"`
+		AfterID  string `json:"after_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	in.ColumnID = strings.TrimSpace(in.ColumnID)
+	in.BeforeID = strings.TrimSpace(in.BeforeID)
+	in.AfterID = strings.TrimSpace(in.AfterID)
+	pos := placement{BeforeID: in.BeforeID, AfterID: in.AfterID}
+	if err := validateMove(id, in.ColumnID, pos); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	override, err := overrideWIPParam(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	before, err := lockTask(ctx, tx, id, in.ColumnID)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if !checkTaskPrecondition(w, r, before) {
+		return
+	}
+	if err := placeTask(ctx, tx, before, in.ColumnID, pos); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	out, err := scanTask(tx.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	by := actorFromRequest(r)
+	if err := enforceWIP(ctx, tx, by, &before, out, override); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := auditTask(ctx, tx, by, &before, &out); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(out))
+	writeJSON(w, http.StatusOK, out)
+}
+
+// validateMove checks the target of a move request before any row is locked.
+func validateMove(taskID, columnID string, pos placement) error {
+	if !isUUID(columnID) {
+		return errors.New("column_id is required")
+	}
+	if pos.BeforeID != "" && pos.AfterID != "" {
+		return errors.New("specify only one of before_id or after_id")
+	}
+	for _, anchor := range []string{pos.BeforeID, pos.AfterID} {
+		if anchor != "" && !isUUID(anchor) {
+			return errors.New("invalid anchor task id")
+		}
+		if anchor == taskID {
+			return errors.New("a task cannot be placed relative to itself")
+		}
+	}
+	return nil
+}
+
+// lockTask locks the task's current column, the optional target column and
+// then the task row itself, returning the locked snapshot. Every mutation
+// takes locks in this order (columns by id, then the task) so concurrent moves
+// touching the same columns are serialised instead of deadlocking or
+// producing duplicate positions.
+//
+// Archived tasks cannot be changed until they are restored, and nothing can
+// be moved into an archived column.
+func lockTask(ctx context.Context, tx *sql.Tx, taskID, targetColumn string) (task, error) {
+	t, err := lockTaskIncludingArchived(ctx, tx, taskID, targetColumn)
+	if err != nil {
+		return task{}, err
+	}
+	if t.ArchivedAt != nil {
+		return task{}, newStatusError(http.StatusConflict, "task is archived, restore it first")
+	}
+	return t, nil
+}
+
+// lockTaskIncludingArchived is lockTask for the endpoints that act on
+// archived tasks: restore and permanent deletion.
+func lockTaskIncludingArchived(ctx context.Context, tx *sql.Tx, taskID, targetColumn string) (task, error) {
+	lockRows, err := tx.QueryContext(ctx, `
+		SELECT id::text, archived_at IS NOT NULL
+		FROM public.api_columns
+		WHERE id = NULLIF($1, '')::uuid OR id = (SELECT column_id FROM public.api_tasks WHERE id = $2)
+		ORDER BY id
+		FOR UPDATE`, targetColumn, taskID)
+	if err != nil {
+		return task{}, err
+	}
+	locked := make(map[string]bool)
+	archived := make(map[string]bool)
+	for lockRows.Next() {
+		var id string
+		var isArchived bool
+		if err := lockRows.Scan(&id, &isArchived); err != nil {
+			lockRows.Close()
+			return task{}, err
+		}
+		locked[id], archived[id] = true, isArchived
+	}
+	if err := lockRows.Err(); err != nil {
+		return task{}, err
+	}
+	if targetColumn != "" && !locked[targetColumn] {
+		return task{}, newStatusError(http.StatusNotFound, "column not found")
+	}
+	if targetColumn != "" && archived[targetColumn] {
+		return task{}, newStatusError(http.StatusConflict, "column is archived")
+	}
+
+	t, err := scanTask(tx.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1
+		FOR UPDATE`, taskID,
+	))
+	if err != nil {
+		return task{}, err
+	}
+	if t.ColumnID != nil && !locked[*t.ColumnID] {
+		return task{}, newStatusError(http.StatusConflict, "task was moved concurrently, please retry")
+	}
+	return t, nil
+}
+
+// placeTask moves a task locked by lockTask into columnID at the requested
+// position, assigning it a rank between its new neighbours. When no key fits
+// between them the whole target column is re-ranked. The integer "order" of
+// both the source and target columns is renumbered to match, and a task that
+// changes boards loses the labels of its old board.
+func placeTask(ctx context.Context, tx *sql.Tx, t task, columnID string, pos placement) error {
+	taskID := t.ID
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id::text, rank
+		FROM public.api_tasks
+		WHERE column_id = $1 AND id <> $2 AND archived_at IS NULL
+		ORDER BY rank ASC NULLS LAST, "order" ASC, created_at ASC`, columnID, taskID)
+	if err != nil {
+		return err
+	}
+	var siblings []rankedTask
+	for rows.Next() {
+		var t rankedTask
+		if err := rows.Scan(&t.id, &t.rank); err != nil {
+			rows.Close()
+			return err
+		}
+		siblings = append(siblings, t)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	index, err := placementIndex(siblings, pos)
+	if err != nil {
+		return err
+	}
+
+	var prev, next sql.NullString
+	prev.Valid, next.Valid = true, true
+	if index > 0 {
+		prev = siblings[index-1].rank
+	}
+	if index < len(siblings) {
+		next = siblings[index].rank
+	}
+	rank, ok := "", false
+	if prev.Valid && next.Valid {
+		rank, ok = rankBetween(prev.String, next.String)
+	}
+	if !ok {
+		// No room (or unranked neighbours): spread the whole column again.
+		ranks := spreadRanks(len(siblings) + 1)
+		rank = ranks[index]
+		for i, sibling := range siblings {
+			r := ranks[i]
+			if i >= index {
+				r = ranks[i+1]
+			}
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE public.api_tasks SET rank = $1 WHERE id = $2`, r, sibling.id,
+			); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE public.api_tasks
+		SET column_id = $1, rank = $2, version = version + 1, updated_at = timezone('utc'::text, now())
+		WHERE id = $3`, columnID, rank, taskID,
+	); err != nil {
+		return err
+	}
+
+	if err := renumberColumn(ctx, tx, columnID); err != nil {
+		return err
+	}
+	if t.ColumnID != nil && *t.ColumnID != columnID {
+		if err := detachForeignLabels(ctx, tx, taskID, columnID); err != nil {
+			return err
+		}
+		return renumberColumn(ctx, tx, *t.ColumnID)
+	}
+	return nil
+}
+
+func placementIndex(siblings []rankedTask, pos placement) (int, error) {
+	anchor := pos.BeforeID
+	if anchor == "" {
+		anchor = pos.AfterID
+	}
+	if anchor != "" {
+		for i, t := range siblings {
+			if t.id != anchor {
+				continue
+			}
+			if pos.AfterID != "" {
+				return i + 1, nil
+			}
+			return i, nil
+		}
+		return 0, newStatusError(http.StatusUnprocessableEntity, "anchor task is not in the target column")
+	}
+	if pos.Index != nil {
+		return min(max(*pos.Index, 0), len(siblings)), nil
+	}
+	return len(siblings), nil
+}
+
+// renumberColumn rewrites the legacy integer "order" of a column's tasks so it
+// stays consistent with their rank for clients that still sort by it.
+func renumberColumn(ctx context.Context, tx *sql.Tx, columnID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE public.api_tasks t
+		SET "order" = o.position
+		FROM (
+			SELECT id, (row_number() OVER (ORDER BY rank ASC NULLS LAST, "order" ASC, created_at ASC) - 1)::int AS position
+			FROM public.api_tasks
+			WHERE column_id = $1 AND archived_at IS NULL
+		) o
+		WHERE t.id = o.id AND t."order" IS DISTINCT FROM o.position`, columnID)
+	return err
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPlacementIndex(t *testing.T) {
+	siblings := []rankedTask{{id: "a"}, {id: "b"}, {id: "c"}}
+	three, negative := 3, -4
+
+	cases := []struct {
+		name string
+		pos  placement
+		want int
+	}{
+		{"append by default", placement{}, 3},
+		{"before anchor", placement{BeforeID: "b"}, 1},
+		{"after anchor", placement{AfterID: "b"}, 2},
+		{"after last", placement{AfterID: "c"}, 3},
+		{"index clamps high", placement{Index: &three}, 3},
+		{"index clamps low", placement{Index: &negative}, 0},
+	}
+	for _, tc := range cases {
+		got, err := placementIndex(siblings, tc.pos)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, got)
+		}
+	}
+
+	_, err := placementIndex(siblings, placement{AfterID: "missing"})
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) || statusErr.status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for unknown anchor, got %v", err)
+	}
+}
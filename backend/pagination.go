@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+// pageCursor is the keyset position of the last row on a page. It is handed
+// to clients as an opaque base64 token.
+type pageCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// page is the envelope returned by paginated list endpoints; the frontend's
+// unwrapArray already understands the data field.
+type page[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func encodeCursor(c pageCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(v string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(v)
+	if err != nil {
+		return c, errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.CreatedAt.IsZero() || !isUUID(c.ID) {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+func parseLimit(q url.Values) (int, error) {
+	v := strings.TrimSpace(q.Get("limit"))
+	if v == "" {
+		return defaultPageLimit, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, errors.New("invalid limit")
+	}
+	return min(n, maxPageLimit), nil
+}
+
+// listParam collects the values of the first query parameter present among
+// keys, splitting comma-separated lists and dropping empty entries.
+func listParam(q url.Values, keys ...string) []string {
+	var out []string
+	for _, key := range keys {
+		for _, raw := range q[key] {
+			for _, v := range strings.Split(raw, ",") {
+				if v = strings.TrimSpace(v); v != "" {
+					out = append(out, v)
+				}
+			}
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return out
+}
+
+func timeParam(q url.Values, key string) (*time.Time, error) {
+	v := strings.TrimSpace(q.Get(key))
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", key)
+	}
+	return &t, nil
+}
+
+// whereBuilder accumulates AND-ed SQL conditions with numbered placeholders.
+type whereBuilder struct {
+	clauses []string
+	args    []any
+}
+
+// arg registers a value and returns its placeholder.
+func (b *whereBuilder) arg(v any) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *whereBuilder) add(clause string) {
+	b.clauses = append(b.clauses, clause)
+}
+
+func (b *whereBuilder) sql() string {
+	if len(b.clauses) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.clauses, " AND ")
+}
+
+// addPageFilters applies the created_after/created_before range and the
+// keyset cursor shared by every endpoint ordered by (created_at, id) DESC.
+func (b *whereBuilder) addPageFilters(q url.Values, createdAt, id string) error {
+	after, err := timeParam(q, "created_after")
+	if err != nil {
+		return err
+	}
+	if after != nil {
+		b.add(createdAt + " > " + b.arg(*after))
+	}
+	before, err := timeParam(q, "created_before")
+	if err != nil {
+		return err
+	}
+	if before != nil {
+		b.add(createdAt + " < " + b.arg(*before))
+	}
+	if v := strings.TrimSpace(q.Get("cursor")); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return err
+		}
+		b.add(fmt.Sprintf("(%s, %s) < (%s, %s::uuid)", createdAt, id, b.arg(c.CreatedAt), b.arg(c.ID)))
+	}
+	return nil
+}
+
+// newPage trims a result fetched with limit+1 rows down to limit and derives
+// the next cursor from the last row kept.
+func newPage[T any](items []T, limit int, key func(T) pageCursor) page[T] {
+	out := page[T]{Data: items}
+	if len(items) > limit {
+		out.Data = items[:limit]
+		next := encodeCursor(key(out.Data[limit-1]))
+		out.NextCursor = &next
+	}
+	return out
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	in := pageCursor{CreatedAt: time.Date(2026, 3, 1, 10, 0, 0, 123, time.UTC), ID: "6f1c2a9e-3b4d-4c5e-8f70-123456789abc"}
+
+	out, err := decodeCursor(encodeCursor(in))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !out.CreatedAt.Equal(in.CreatedAt) || out.ID != in.ID {
+		t.Fatalf("expected %+v, got %+v", in, out)
+	}
+
+	for _, bad := range []string{"not-base64!", encodeCursor(pageCursor{ID: in.ID})} {
+		if _, err := decodeCursor(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestParseLimitBounds(t *testing.T) {
+	cases := map[string]int{"": defaultPageLimit, "25": 25, "100000": maxPageLimit}
+	for raw, want := range cases {
+		got, err := parseLimit(url.Values{"limit": {raw}})
+		if err != nil || got != want {
+			t.Fatalf("limit=%q: expected %d, got %d (%v)", raw, want, got, err)
+		}
+	}
+	for _, raw := range []string{"0", "-1", "abc"} {
+		if _, err := parseLimit(url.Values{"limit": {raw}}); err == nil {
+			t.Fatalf("expected limit=%q to be rejected", raw)
+		}
+	}
+}
+
+func TestNewPageSetsNextCursorOnlyWhenMoreRows(t *testing.T) {
+	key := func(v task) pageCursor { return pageCursor{CreatedAt: v.CreatedAt, ID: v.ID} }
+	items := []task{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	full := newPage(items, 2, key)
+	if len(full.Data) != 2 || full.NextCursor == nil {
+		t.Fatalf("expected a trimmed page with a cursor, got %+v", full)
+	}
+	last := newPage(items, 3, key)
+	if len(last.Data) != 3 || last.NextCursor != nil {
+		t.Fatalf("expected the final page without a cursor, got %+v", last)
+	}
+}
+
+func TestTaskFilterBuildsConditions(t *testing.T) {
+	q := url.Values{
+		"columnIds":     {"6f1c2a9e-3b4d-4c5e-8f70-123456789abc,7f1c2a9e-3b4d-4c5e-8f70-123456789abc"},
+		"status":        {"todo"},
+		"created_after": {"2026-01-01T00:00:00Z"},
+	}
+	where, err := taskFilter(q, defaultWorkflow)
+	if err != nil {
+		t.Fatalf("taskFilter: %v", err)
+	}
+	sql := where.sql()
+	for _, want := range []string{"status = ANY($1)", "column_id = ANY($2::uuid[])", "created_at > $3"} {
+		if !strings.Contains(sql, want) {
+			t.Fatalf("expected %q in %q", want, sql)
+		}
+	}
+	if cols := where.args[1].([]string); len(cols) != 2 {
+		t.Fatalf("expected two column ids, got %v", cols)
+	}
+
+	where, err = taskFilter(url.Values{"columnIds": {"col-1,00000000-0000-0000-0000-000000000001"}}, defaultWorkflow)
+	if err != nil {
+		t.Fatalf("expected legacy column keys to be accepted: %v", err)
+	}
+	if cols := where.args[0].([]string); len(cols) != 1 {
+		t.Fatalf("expected legacy column keys to match nothing, got %v", cols)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import "strings"
+
+// Task ranks are base-36 fractional keys compared bytewise (the rank column
+// uses the "C" collation). A key sorts as the fraction 0.<digits>, so there is
+// always room between two keys until maxRankLength is reached, at which point
+// the column is rebalanced with spreadRanks.
+const (
+	rankAlphabet  = "0123456789abcdefghijklmnopqrstuvwxyz"
+	rankBase      = len(rankAlphabet)
+	maxRankLength = 12
+)
+
+func rankDigit(c byte) int {
+	return strings.IndexByte(rankAlphabet, c)
+}
+
+// rankBetween returns a key strictly between prev and next. An empty prev
+// means "before everything" and an empty next means "after everything". It
+// reports false when the keys are out of order, malformed, or no key fits
+// within maxRankLength.
+func rankBetween(prev, next string) (string, bool) {
+	if next != "" && prev >= next {
+		return "", false
+	}
+
+	out := make([]byte, 0, maxRankLength)
+	// Once the result is known to sort below next, only prev bounds it.
+	bounded := next != ""
+	for i := 0; i < maxRankLength; i++ {
+		lo := 0
+		if i < len(prev) {
+			if lo = rankDigit(prev[i]); lo < 0 {
+				return "", false
+			}
+		}
+		hi := rankBase
+		if bounded {
+			hi = 0
+			if i < len(next) {
+				if hi = rankDigit(next[i]); hi < 0 {
+					return "", false
+				}
+			}
+		}
+
+		switch {
+		case hi-lo > 1:
+			return string(append(out, rankAlphabet[(lo+hi)/2])), true
+		case hi-lo == 1:
+			bounded = false
+		case hi < lo:
+			return "", false
+		}
+		out = append(out, rankAlphabet[lo])
+	}
+	return "", false
+}
+
+// spreadRanks returns n evenly spaced, strictly increasing keys leaving room
+// for roughly two characters of further inserts between neighbours.
+func spreadRanks(n int) []string {
+	width := 1
+	space := uint64(rankBase)
+	for space < uint64(n+1)*uint64(rankBase) {
+		width++
+		space *= uint64(rankBase)
+	}
+	step := space / uint64(n+1)
+
+	out := make([]string, n)
+	buf := make([]byte, width)
+	for i := range out {
+		v := uint64(i+1) * step
+		for j := width - 1; j >= 0; j-- {
+			buf[j] = rankAlphabet[v%uint64(rankBase)]
+			v /= uint64(rankBase)
+		}
+		// Trailing zeros do not change the key's value but would leave no
+		// room for keys sorting between it and its own prefix.
+		out[i] = strings.TrimRight(string(buf), "0")
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestRankBetweenOrdersKeys(t *testing.T) {
+	cases := []struct{ prev, next string }{
+		{"", ""},
+		{"", "1"},
+		{"i", ""},
+		{"a", "b"},
+		{"a", "a1"},
+		{"az", "b"},
+		{"zz", ""},
+	}
+	for _, tc := range cases {
+		got, ok := rankBetween(tc.prev, tc.next)
+		if !ok {
+			t.Fatalf("rankBetween(%q, %q) found no key", tc.prev, tc.next)
+		}
+		if got <= tc.prev || (tc.next != "" && got >= tc.next) {
+			t.Fatalf("rankBetween(%q, %q) = %q, not strictly between", tc.prev, tc.next, got)
+		}
+	}
+}
+
+func TestRankBetweenRejectsInvalidBounds(t *testing.T) {
+	for _, tc := range []struct{ prev, next string }{
+		{"b", "a"},
+		{"a", "a"},
+		{"A", ""},
+	} {
+		if got, ok := rankBetween(tc.prev, tc.next); ok {
+			t.Fatalf("rankBetween(%q, %q) = %q, expected failure", tc.prev, tc.next, got)
+		}
+	}
+}
+
+func TestRankBetweenRunsOutOfRoomAndSpreadRecovers(t *testing.T) {
+	prev, next := "a", "b"
+	exhausted := false
+	for i := 0; i < 1000; i++ {
+		key, ok := rankBetween(prev, next)
+		if !ok {
+			exhausted = true
+			break
+		}
+		next = key
+	}
+	if !exhausted {
+		t.Fatal("expected repeated inserts into the same gap to exhaust the key space")
+	}
+
+	keys := spreadRanks(50)
+	if !sort.StringsAreSorted(keys) {
+		t.Fatalf("expected spread keys to be sorted: %v", keys)
+	}
+	for i := 1; i < len(keys); i++ {
+		if keys[i] == keys[i-1] {
+			t.Fatalf("duplicate spread key %q", keys[i])
+		}
+		if _, ok := rankBetween(keys[i-1], keys[i]); !ok {
+			t.Fatalf("no room between spread keys %q and %q", keys[i-1], keys[i])
+		}
+	}
+	if _, ok := rankBetween("", keys[0]); !ok {
+		t.Fatal("no room before first spread key")
+	}
+}
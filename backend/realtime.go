@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// realtimeSchema makes Postgres announce log inserts and task changes on
+// NOTIFY channels, whichever instance or client wrote them. Payloads carry
+// keys only, as NOTIFY payloads are limited to 8000 bytes; listeners read
+// the rows themselves.
+//
+// Instances booting together take turns under an advisory lock, and the
+// triggers are only created when missing, so a boot takes no lock on the
+// tables. A trigger whose definition changes needs a new name.
+var realtimeSchema = []string{
+	`DO $do$
+	BEGIN
+		PERFORM pg_advisory_xact_lock(hashtext('api_notify_change'));
+
+		CREATE OR REPLACE FUNCTION public.api_notify_change() RETURNS trigger
+		LANGUAGE plpgsql AS $fn$
+		DECLARE
+			rec RECORD;
+			payload jsonb;
+		BEGIN
+			IF TG_OP = 'DELETE' THEN
+				rec := OLD;
+			ELSE
+				rec := NEW;
+			END IF;
+			IF TG_TABLE_NAME = 'api_logs' THEN
+				payload := jsonb_build_object('table', 'logs', 'op', lower(TG_OP), 'id', rec.id,
+					'agent_id', rec.agent_id, 'task_id', rec.task_id, 'at', rec.created_at);
+			ELSE
+				payload := jsonb_build_object('table', 'tasks', 'op', lower(TG_OP), 'id', rec.id,
+					'column_id', rec.column_id,
+					'board_id', (SELECT board_id FROM public.api_columns WHERE id = rec.column_id),
+					'agent_id', rec.assigned_to, 'version', rec.version, 'at', rec.updated_at);
+				-- The source board needs to hear about moves too.
+				IF TG_OP = 'UPDATE' AND OLD.column_id IS DISTINCT FROM NEW.column_id THEN
+					payload := payload || jsonb_build_object('old_column_id', OLD.column_id,
+						'old_board_id', (SELECT board_id FROM public.api_columns WHERE id = OLD.column_id));
+				END IF;
+			END IF;
+			PERFORM pg_notify(TG_ARGV[0], payload::text);
+			RETURN NULL;
+		END $fn$;
+
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger
+			WHERE tgrelid = 'public.api_logs'::regclass AND tgname = 'api_logs_notify') THEN
+			CREATE TRIGGER api_logs_notify
+				AFTER INSERT ON public.api_logs
+				FOR EACH ROW EXECUTE FUNCTION public.api_notify_change('` + logsChannel + `');
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger
+			WHERE tgrelid = 'public.api_tasks'::regclass AND tgname = 'api_tasks_notify') THEN
+			CREATE TRIGGER api_tasks_notify
+				AFTER INSERT OR UPDATE OR DELETE ON public.api_tasks
+				FOR EACH ROW EXECUTE FUNCTION public.api_notify_change('` + tasksChannel + `');
+		END IF;
+	END $do$`,
+	// Read when replaying after a reconnect.
+	`CREATE INDEX IF NOT EXISTS api_tasks_updated_idx ON public.api_tasks (updated_at)`,
+	`CREATE INDEX IF NOT EXISTS api_task_history_deleted_idx ON public.api_task_history (created_at) WHERE action = 'delete'`,
+}
+
+const (
+	logsChannel  = "hq_logs"
+	tasksChannel = "hq_tasks"
+)
+
+const (
+	realtimeMinBackoff = 500 * time.Millisecond
+	realtimeMaxBackoff = 30 * time.Second
+	// realtimePingInterval bounds how long a dead listener connection goes
+	// unnoticed.
+	realtimePingInterval = 30 * time.Second
+	realtimeBuffer       = 64
+	// realtimeReplayLimit keeps a replay well inside a subscriber's buffer;
+	// when more changed, a resync is sent instead.
+	realtimeReplayLimit = realtimeBuffer / 2
+	// realtimeReplaySlack widens the task replay window for rows that
+	// commit after newer ones: updated_at is the start of the updating
+	// transaction, and a sweep may run for up to 30 seconds.
+	realtimeReplaySlack = 45 * time.Second
+)
+
+// changeEvent is a change announced by the database. Table is "logs" or
+// "tasks" and Op one of insert, update and delete; a task moved to another
+// column also carries the column and board it left. After a reconnect the
+// log inserts, task changes and task deletions missed meanwhile are sent
+// again with Replayed set. If more than realtimeReplayLimit were missed, a
+// single event with Op "resync" and no table is sent instead, and
+// subscribers should then reload what they show. Events are hints and may
+// repeat.
+type changeEvent struct {
+	Table    string    `json:"table,omitempty"`
+	Op       string    `json:"op"`
+	ID       string    `json:"id,omitempty"`
+	BoardID  *string   `json:"board_id,omitempty"`
+	ColumnID *string   `json:"column_id,omitempty"`
+	TaskID   *string   `json:"task_id,omitempty"`
+	AgentID  *string   `json:"agent_id,omitempty"`
+	Version  *int      `json:"version,omitempty"`
+	At       time.Time `json:"at"`
+
+	OldBoardID  *string `json:"old_board_id,omitempty"`
+	OldColumnID *string `json:"old_column_id,omitempty"`
+	Replayed    bool    `json:"replayed,omitempty"`
+}
+
+func parseChangeEvent(payload string) (changeEvent, error) {
+	var e changeEvent
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		return e, err
+	}
+	if e.Table != "logs" && e.Table != "tasks" || e.ID == "" {
+		return e, fmt.Errorf("unexpected payload %q", payload)
+	}
+	return e, nil
+}
+
+// realtimeHub listens on the NOTIFY channels over a dedicated connection
+// and fans the events out to subscribers in this process.
+type realtimeHub struct {
+	dsn string
+
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+
+	// listening is set while notifications are being received.
+	listening atomic.Bool
+
+	// mark is where the last connection was known to be listening; the
+	// next one replays what changed after it. Only run touches it.
+	mark replayMark
+}
+
+// replayMark is a position to replay changes from: the log stream
+// watermark (see logTail) and the database time.
+type replayMark struct {
+	logTxID uint64
+	at      time.Time
+}
+
+func newRealtimeHub(dsn string) *realtimeHub {
+	return &realtimeHub{dsn: dsn, subs: make(map[*subscription]struct{})}
+}
+
+// subscription receives the events of some tables on C. A subscriber that
+// lets C fill up is dropped and C is closed; it should reload from the
+// database and subscribe again.
+type subscription struct {
+	C      chan changeEvent
+	tables []string
+	hub    *realtimeHub
+}
+
+// subscribe registers for events of the given tables, or of all tables when
+// none are given.
+func (h *realtimeHub) subscribe(tables ...string) *subscription {
+	sub := &subscription{C: make(chan changeEvent, realtimeBuffer), tables: tables, hub: h}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Close unsubscribes; it is safe to call after the hub dropped sub.
+func (sub *subscription) Close() {
+	sub.hub.mu.Lock()
+	defer sub.hub.mu.Unlock()
+	sub.hub.drop(sub)
+}
+
+// drop must be called with mu held.
+func (h *realtimeHub) drop(sub *subscription) {
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.C)
+	}
+}
+
+func (h *realtimeHub) publish(e changeEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if e.Table != "" && len(sub.tables) > 0 && !slices.Contains(sub.tables, e.Table) {
+			continue
+		}
+		select {
+		case sub.C <- e:
+		default:
+			h.drop(sub)
+		}
+	}
+}
+
+// reconnectDelay is an exponential backoff with jitter, so instances that
+// lose the database together do not reconnect in lockstep.
+func reconnectDelay(attempt int) time.Duration {
+	d := realtimeMaxBackoff
+	if attempt < 16 {
+		d = min(realtimeMinBackoff<<attempt, realtimeMaxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// run listens until ctx is cancelled, reconnecting whenever the connection
+// is lost.
+func (h *realtimeHub) run(ctx context.Context) {
+	attempt := 0
+	for {
+		listened, err := h.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if listened {
+			attempt = 0
+		}
+		delay := reconnectDelay(attempt)
+		attempt++
+		log.Printf("realtime listener disconnected, retrying in %s: %v", delay.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// listen runs one listener connection and reports whether it got as far as
+// listening.
+func (h *realtimeHub) listen(ctx context.Context) (bool, error) {
+	conn, err := pgx.Connect(ctx, h.dsn)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(context.Background())
+
+	for _, channel := range []string{logsChannel, tasksChannel} {
+		if _, err := conn.Exec(ctx, "LISTEN "+channel); err != nil {
+			return false, err
+		}
+	}
+	// Marked while listening, so everything after the mark either gets
+	// notified or is replayed by the next connection.
+	mark, err := readReplayMark(ctx, conn)
+	if err != nil {
+		return false, err
+	}
+	if !h.mark.at.IsZero() {
+		events, err := loadReplay(ctx, conn, h.mark)
+		if err != nil {
+			return true, err
+		}
+		h.publishReplay(events)
+	}
+	h.mark = mark
+	h.listening.Store(true)
+	defer h.listening.Store(false)
+
+	// The mark doubles as the ping, and moves on even while notifications
+	// keep arriving.
+	due := time.Now().Add(realtimePingInterval)
+	for {
+		wait, cancel := context.WithDeadline(ctx, due)
+		n, err := conn.WaitForNotification(wait)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				if h.mark, err = readReplayMark(ctx, conn); err != nil {
+					return true, err
+				}
+				due = time.Now().Add(realtimePingInterval)
+				continue
+			}
+			return true, err
+		}
+		e, err := parseChangeEvent(n.Payload)
+		if err != nil {
+			log.Printf("realtime: ignoring notification on %s: %v", n.Channel, err)
+			continue
+		}
+		h.publish(e)
+	}
+}
+
+func readReplayMark(ctx context.Context, conn *pgx.Conn) (replayMark, error) {
+	var m replayMark
+	var txid string
+	// The same expression as the created_at and updated_at defaults.
+	err := conn.QueryRow(ctx, `SELECT pg_snapshot_xmin(pg_current_snapshot())::text, timezone('utc'::text, now())::timestamptz`).Scan(&txid, &m.at)
+	if err != nil {
+		return m, err
+	}
+	m.logTxID, err = strconv.ParseUint(txid, 10, 64)
+	return m, err
+}
+
+// loadReplay reads the changes made after from, up to one more than
+// realtimeReplayLimit. Logs follow their watermark exactly; task changes
+// are read by updated_at and deletions from the task history.
+func loadReplay(ctx context.Context, conn *pgx.Conn, from replayMark) ([]changeEvent, error) {
+	limit := realtimeReplayLimit + 1
+	since := from.at.Add(-realtimeReplaySlack)
+	queries := []struct {
+		sql  string
+		arg  any
+		scan func(pgx.CollectableRow) (changeEvent, error)
+	}{
+		{`SELECT id::text, agent_id, task_id::text, created_at
+			FROM public.api_logs
+			WHERE txid >= $1::text::xid8
+			ORDER BY txid, id
+			LIMIT $2`, strconv.FormatUint(from.logTxID, 10),
+			func(row pgx.CollectableRow) (changeEvent, error) {
+				e := changeEvent{Table: "logs", Op: "insert", Replayed: true}
+				return e, row.Scan(&e.ID, &e.AgentID, &e.TaskID, &e.At)
+			}},
+		{`SELECT t.id::text, t.column_id::text, c.board_id::text, t.assigned_to, t.version, t.updated_at
+			FROM public.api_tasks t
+			LEFT JOIN public.api_columns c ON c.id = t.column_id
+			WHERE t.updated_at > $1
+			ORDER BY t.updated_at, t.id
+			LIMIT $2`, since,
+			func(row pgx.CollectableRow) (changeEvent, error) {
+				e := changeEvent{Table: "tasks", Op: "update", Replayed: true}
+				return e, row.Scan(&e.ID, &e.ColumnID, &e.BoardID, &e.AgentID, &e.Version, &e.At)
+			}},
+		{`SELECT h.task_id::text, h.old_values->>'column_id', c.board_id::text, h.old_values->>'assigned_to', h.created_at
+			FROM public.api_task_history h
+			LEFT JOIN public.api_columns c ON c.id::text = h.old_values->>'column_id'
+			WHERE h.action = 'delete' AND h.created_at > $1
+			ORDER BY h.created_at, h.id
+			LIMIT $2`, since,
+			func(row pgx.CollectableRow) (changeEvent, error) {
+				e := changeEvent{Table: "tasks", Op: "delete", Replayed: true}
+				return e, row.Scan(&e.ID, &e.ColumnID, &e.BoardID, &e.AgentID, &e.At)
+			}},
+	}
+
+	var events []changeEvent
+	for _, q := range queries {
+		rows, err := conn.Query(ctx, q.sql, q.arg, limit)
+		if err != nil {
+			return nil, err
+		}
+		loaded, err := pgx.CollectRows(rows, q.scan)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, loaded...)
+		if len(events) > realtimeReplayLimit {
+			break
+		}
+	}
+	return events, nil
+}
+
+// publishReplay publishes the replayed events, or a single resync when there
+// are too many to send without filling subscribers' buffers.
+func (h *realtimeHub) publishReplay(events []changeEvent) {
+	if len(events) > realtimeReplayLimit {
+		h.publish(changeEvent{Op: "resync", At: time.Now()})
+		return
+	}
+	for _, e := range events {
+		h.publish(e)
+	}
+}
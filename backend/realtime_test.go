@@ -0,0 +1,120 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseChangeEvent(t *testing.T) {
+	e, err := parseChangeEvent(`{"table": "tasks", "op": "update", "id": "t1", "board_id": "b1", "column_id": "c1", "agent_id": null, "version": 3, "at": "2026-03-01T12:00:00.123456+00:00"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.BoardID == nil || *e.BoardID != "b1" || e.AgentID != nil || *e.Version != 3 || e.At.Nanosecond() != 123456000 {
+		t.Fatalf("unexpected event %+v", e)
+	}
+	for _, payload := range []string{`nope`, `{"table": "columns", "id": "x"}`, `{"table": "logs"}`} {
+		if _, err := parseChangeEvent(payload); err == nil {
+			t.Errorf("expected %s to be rejected", payload)
+		}
+	}
+}
+
+func TestRealtimeHubFansOutByTable(t *testing.T) {
+	h := newRealtimeHub("")
+	logs := h.subscribe("logs")
+	all := h.subscribe()
+	defer logs.Close()
+	defer all.Close()
+
+	h.publish(changeEvent{Table: "tasks", Op: "update", ID: "t1"})
+	h.publish(changeEvent{Table: "logs", Op: "insert", ID: "l1"})
+	h.publish(changeEvent{Op: "resync"})
+
+	if got := drain(logs); len(got) != 2 || got[0].ID != "l1" || got[1].Op != "resync" {
+		t.Fatalf("expected the log insert and the resync, got %+v", got)
+	}
+	if got := drain(all); len(got) != 3 {
+		t.Fatalf("expected every event, got %+v", got)
+	}
+}
+
+func TestRealtimeHubDropsSlowSubscribers(t *testing.T) {
+	h := newRealtimeHub("")
+	sub := h.subscribe()
+	for range realtimeBuffer + 1 {
+		h.publish(changeEvent{Table: "logs", Op: "insert", ID: "l"})
+	}
+
+	if got := drain(sub); len(got) != realtimeBuffer {
+		t.Fatalf("expected a full buffer before the drop, got %d events", len(got))
+	}
+	if _, ok := <-sub.C; ok {
+		t.Fatal("expected the subscription to be closed")
+	}
+	if len(h.subs) != 0 {
+		t.Fatal("expected the subscription to be removed")
+	}
+	// Closing after the hub dropped it must not panic.
+	sub.Close()
+}
+
+func TestRealtimeHubReplayStaysInsideBuffers(t *testing.T) {
+	h := newRealtimeHub("")
+	sub := h.subscribe()
+	defer sub.Close()
+	// A subscriber that is busy but keeping up.
+	for range realtimeBuffer - realtimeReplayLimit {
+		h.publish(changeEvent{Table: "logs", Op: "insert", ID: "l"})
+	}
+
+	var replay []changeEvent
+	for range realtimeReplayLimit {
+		replay = append(replay, changeEvent{Table: "logs", Op: "insert", ID: "l", Replayed: true})
+	}
+	h.publishReplay(replay)
+	if got := drain(sub); len(got) != realtimeBuffer || !got[len(got)-1].Replayed {
+		t.Fatalf("expected the missed rows to be replayed, got %d events", len(got))
+	}
+
+	for range realtimeBuffer - realtimeReplayLimit {
+		h.publish(changeEvent{Table: "logs", Op: "insert", ID: "l"})
+	}
+	h.publishReplay(append(replay, changeEvent{Table: "tasks", Op: "delete", ID: "t", Replayed: true}))
+	got := drain(sub)
+	if len(got) != realtimeBuffer-realtimeReplayLimit+1 || got[len(got)-1].Op != "resync" {
+		t.Fatalf("expected an oversized replay to become a single resync, got %d events", len(got))
+	}
+	if _, ok := h.subs[sub]; !ok {
+		t.Fatal("expected the subscriber to stay subscribed through the replay")
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	for attempt, ceiling := range []time.Duration{realtimeMinBackoff, 2 * realtimeMinBackoff, 4 * realtimeMinBackoff} {
+		for range 20 {
+			if d := reconnectDelay(attempt); d < ceiling/2 || d > ceiling {
+				t.Fatalf("attempt %d: delay %s outside [%s, %s]", attempt, d, ceiling/2, ceiling)
+			}
+		}
+	}
+	if d := reconnectDelay(100); d > realtimeMaxBackoff || d < realtimeMaxBackoff/2 {
+		t.Fatalf("expected the delay to be capped, got %s", d)
+	}
+}
+
+// drain returns the events buffered on sub without blocking.
+func drain(sub *subscription) []changeEvent {
+	var out []changeEvent
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return out
+			}
+			out = append(out, e)
+		default:
+			return out
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Each field is a bit set of allowed values.
+// As in cron, when both day fields are restricted a day matches either.
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+	loc                           *time.Location
+}
+
+// scheduleMacros are the shorthand rules cron accepts.
+var scheduleMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// parseSchedule parses a cron expression such as "0 9 * * mon-fri" or a
+// macro such as "@weekly", evaluated in the named IANA time zone.
+func parseSchedule(expr, timezone string) (*schedule, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, errors.New("recurrence must not be empty")
+	}
+	if macro, ok := scheduleMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("recurrence %q must have %d fields: minute hour day-of-month month day-of-week", expr, len(cronFields))
+	}
+	loc, err := time.LoadLocation(strings.TrimSpace(timezone))
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", timezone)
+	}
+
+	sets := make([]uint64, len(parts))
+	for i, part := range parts {
+		if sets[i], err = parseCronField(part, cronFields[i]); err != nil {
+			return nil, err
+		}
+	}
+	// Sunday may be written as 0 or 7.
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+	return &schedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: parts[2] == "*" || parts[2] == "?",
+		dowAny: parts[4] == "*" || parts[4] == "?",
+		loc:    loc,
+	}, nil
+}
+
+// parseCronField handles lists of values, ranges and steps, e.g. "1,15",
+// "mon-fri", "*/15" or "8-18/2".
+func parseCronField(v string, f cronField) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(strings.ToLower(v), ",") {
+		rng, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, v)
+			}
+			rng, step = item[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, v)
+			}
+		default:
+			n, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = f.max
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			set |= 1 << n
+		}
+	}
+	return set, nil
+}
+
+func (f cronField) value(v string) (int, error) {
+	for i, name := range f.names {
+		if v == name {
+			return i + f.min, nil
+		}
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s must be between %d and %d, got %q", f.name, f.min, f.max, v)
+	}
+	return n, nil
+}
+
+// maxScheduleSearch bounds next for rules that can never fire, such as
+// "0 0 30 2 *".
+const maxScheduleSearch = 5 * 366 * 24 * time.Hour
+
+// next returns the first time strictly after t that matches the schedule,
+// or the zero time if there is none within five years. Wall clock times that
+// a DST change skips never match, and those it repeats match only the first
+// time round.
+func (s *schedule) next(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxScheduleSearch)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc))
+		case !s.matchDay(t):
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc))
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = nextHour(t)
+		case s.minute&(1<<uint(t.Minute())) == 0 || repeatedWallClock(t):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// nextHour steps to the start of the next hour in absolute time. Building it
+// with time.Date would loop when that hour falls in a DST gap, as time.Date
+// resolves such wall clock times to an earlier instant.
+func nextHour(t time.Time) time.Time {
+	return t.Add(time.Duration(60-t.Minute()) * time.Minute)
+}
+
+// forward returns next unless time.Date resolved it into the past because
+// its wall clock time falls in a DST gap, e.g. a midnight that is skipped;
+// the search then goes on from the next hour.
+func forward(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return nextHour(t)
+}
+
+// repeatedWallClock reports whether the wall clock time of t already
+// occurred at an earlier instant, in the hour repeated when clocks go back.
+func repeatedWallClock(t time.Time) bool {
+	start, _ := t.ZoneBounds()
+	if start.IsZero() {
+		return false
+	}
+	_, offset := t.Zone()
+	_, before := start.Add(-time.Second).Zone()
+	return before > offset && t.Sub(start) < time.Duration(before-offset)*time.Second
+}
+
+func (s *schedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// upcoming lists the next n times after t.
+func (s *schedule) upcoming(t time.Time, n int) []time.Time {
+	out := make([]time.Time, 0, n)
+	for len(out) < n {
+		if t = s.next(t); t.IsZero() {
+			break
+		}
+		out = append(out, t)
+	}
+	return out
+}
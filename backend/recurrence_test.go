@@ -0,0 +1,132 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func mustSchedule(t *testing.T, expr, tz string) *schedule {
+	t.Helper()
+	s, err := parseSchedule(expr, tz)
+	if err != nil {
+		t.Fatalf("parseSchedule(%q): %v", expr, err)
+	}
+	return s
+}
+
+func TestScheduleNext(t *testing.T) {
+	from := time.Date(2026, 10, 16, 10, 30, 0, 0, time.UTC) // a Friday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 10, 16, 10, 45, 0, 0, time.UTC)},
+		{"0 9 * * mon-fri", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 10 * * *", time.Date(2026, 10, 17, 10, 30, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matches.
+		{"0 0 20 * fri", time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		if got := mustSchedule(t, tc.expr, "UTC").next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: next = %s, want %s", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestScheduleNextInTimezone(t *testing.T) {
+	s := mustSchedule(t, "0 9 * * *", "Asia/Jakarta")
+	got := s.next(time.Date(2026, 10, 16, 3, 0, 0, 0, time.UTC))
+	if want := time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Fatalf("expected 09:00 WIB (%s), got %s", want, got)
+	}
+}
+
+func TestScheduleSkipsDSTGap(t *testing.T) {
+	// 02:00 does not exist in New York on 14 March 2027, nor midnight in
+	// Santiago on 5 September 2027.
+	cases := []struct {
+		expr, tz string
+		hour     int
+		from     time.Time
+		skipped  time.Time
+	}{
+		{"0 2 * * *", "America/New_York", 2, time.Date(2027, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 3, 14, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * *", "America/Santiago", 0, time.Date(2027, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2027, 9, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range cases {
+		s := mustSchedule(t, tc.expr, tc.tz)
+		done := make(chan []time.Time, 1)
+		go func() { done <- s.upcoming(tc.from, 20) }()
+		var got []time.Time
+		select {
+		case got = <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s in %s: upcoming did not return", tc.expr, tc.tz)
+		}
+		if len(got) != 20 {
+			t.Fatalf("%s in %s: got %d times, want 20", tc.expr, tc.tz, len(got))
+		}
+		for i, at := range got {
+			if i > 0 && !at.After(got[i-1]) {
+				t.Fatalf("%s in %s: %s does not follow %s", tc.expr, tc.tz, at, got[i-1])
+			}
+			if at.Hour() != tc.hour || at.Minute() != 0 {
+				t.Errorf("%s in %s: fired at %s", tc.expr, tc.tz, at)
+			}
+			y, m, d := at.Date()
+			if y == tc.skipped.Year() && m == tc.skipped.Month() && d == tc.skipped.Day() {
+				t.Errorf("%s in %s: fired on the day the time is skipped, at %s", tc.expr, tc.tz, at)
+			}
+		}
+	}
+}
+
+func TestScheduleFiresOnceInDSTOverlap(t *testing.T) {
+	// New York repeats 01:00-01:59 on 7 November 2027.
+	from := time.Date(2027, 11, 6, 12, 0, 0, 0, time.UTC)
+	daily := mustSchedule(t, "30 1 * * *", "America/New_York").upcoming(from, 2)
+	want := []time.Time{
+		time.Date(2027, 11, 7, 5, 30, 0, 0, time.UTC), // 01:30 EDT
+		time.Date(2027, 11, 8, 6, 30, 0, 0, time.UTC), // 01:30 EST
+	}
+	if len(daily) != 2 || !daily[0].Equal(want[0]) || !daily[1].Equal(want[1]) {
+		t.Fatalf("expected %v, got %v", want, daily)
+	}
+
+	halfHourly := mustSchedule(t, "*/30 * * * *", "America/New_York").upcoming(time.Date(2027, 11, 7, 4, 45, 0, 0, time.UTC), 3)
+	want = []time.Time{
+		time.Date(2027, 11, 7, 5, 0, 0, 0, time.UTC),  // 01:00 EDT
+		time.Date(2027, 11, 7, 5, 30, 0, 0, time.UTC), // 01:30 EDT
+		time.Date(2027, 11, 7, 7, 0, 0, 0, time.UTC),  // 02:00 EST
+	}
+	for i := range want {
+		if i >= len(halfHourly) || !halfHourly[i].Equal(want[i]) {
+			t.Fatalf("expected %v, got %v", want, halfHourly)
+		}
+	}
+}
+
+func TestScheduleNeverFiring(t *testing.T) {
+	s := mustSchedule(t, "0 0 30 2 *", "UTC")
+	if got := s.next(time.Now()); !got.IsZero() {
+		t.Fatalf("expected no occurrence for 30 February, got %s", got)
+	}
+	if got := s.upcoming(time.Now(), 3); len(got) != 0 {
+		t.Fatalf("expected no upcoming occurrences, got %v", got)
+	}
+}
+
+func TestParseScheduleRejectsInvalidRules(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		if _, err := parseSchedule(expr, "UTC"); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+	if _, err := parseSchedule("@daily", "Mars/Olympus"); err == nil {
+		t.Error("expected an unknown timezone to be rejected")
+	}
+}
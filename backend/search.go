@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// searchConfig is the text search configuration used for both the stored
+// vectors and the queries; they must match for the GIN indexes to apply.
+const searchConfig = "english"
+
+// searchSchema adds generated tsvector columns with GIN indexes. Task titles
+// weigh more than descriptions.
+var searchSchema = []string{
+	`ALTER TABLE public.api_tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (
+			setweight(to_tsvector('` + searchConfig + `', coalesce(title, '')), 'A') ||
+			setweight(to_tsvector('` + searchConfig + `', coalesce(description, '')), 'B')
+		) STORED`,
+	`CREATE INDEX IF NOT EXISTS api_tasks_search_idx ON public.api_tasks USING GIN (search_vector)`,
+	`ALTER TABLE public.api_task_comments ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('` + searchConfig + `', body)) STORED`,
+	`CREATE INDEX IF NOT EXISTS api_task_comments_search_idx ON public.api_task_comments USING GIN (search_vector)`,
+	`ALTER TABLE public.api_logs ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('` + searchConfig + `', message)) STORED`,
+	`CREATE INDEX IF NOT EXISTS api_logs_search_idx ON public.api_logs USING GIN (search_vector)`,
+}
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+	maxSearchQuery     = 200
+)
+
+// searchTypes are the groups GET /api/search can return, in response order.
+var searchTypes = []string{"tasks", "comments", "logs"}
+
+// Highlights are produced with control characters as delimiters so that the
+// matched text can be HTML-escaped before the <mark> tags are added.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+type searchHit struct {
+	ID        string    `json:"id"`
+	TaskID    *string   `json:"task_id,omitempty"`
+	Title     *string   `json:"title,omitempty"`
+	Status    *string   `json:"status,omitempty"`
+	AgentID   *string   `json:"agent_id,omitempty"`
+	Level     *string   `json:"level,omitempty"`
+	Author    *actor    `json:"author,omitempty"`
+	Rank      float64   `json:"rank"`
+	Highlight string    `json:"highlight"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// searchRequest is the parsed query string of GET /api/search.
+type searchRequest struct {
+	Query  string
+	Types  []string
+	Agents []string
+	After  *time.Time
+	Before *time.Time
+	Limit  int
+}
+
+func parseSearchRequest(q url.Values) (searchRequest, error) {
+	in := searchRequest{Query: strings.TrimSpace(q.Get("q")), Limit: defaultSearchLimit}
+	if in.Query == "" {
+		return in, errors.New("q is required")
+	}
+	if len([]rune(in.Query)) > maxSearchQuery {
+		return in, fmt.Errorf("q must be at most %d characters", maxSearchQuery)
+	}
+	in.Types = listParam(q, "type", "types")
+	for _, t := range in.Types {
+		if !slices.Contains(searchTypes, t) {
+			return in, fmt.Errorf("unknown type %q, expected one of %s", t, strings.Join(searchTypes, ", "))
+		}
+	}
+	if len(in.Types) == 0 {
+		in.Types = searchTypes
+	}
+	in.Agents = listParam(q, "agent", "agent_id")
+	var err error
+	if in.After, err = timeParam(q, "created_after"); err != nil {
+		return in, err
+	}
+	if in.Before, err = timeParam(q, "created_before"); err != nil {
+		return in, err
+	}
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return in, errors.New("invalid limit")
+		}
+		in.Limit = min(n, maxSearchLimit)
+	}
+	return in, nil
+}
+
+// renderHighlight escapes a ts_headline fragment and turns its delimiters
+// into <mark> tags, so clients can render it as HTML safely.
+func renderHighlight(fragment string) string {
+	fragment = html.EscapeString(fragment)
+	fragment = strings.ReplaceAll(fragment, highlightStart, "<mark>")
+	return strings.ReplaceAll(fragment, highlightStop, "</mark>")
+}
+
+// searchActiveTask leaves out comments and logs of archived tasks, which
+// would link to tasks hidden from every listing.
+const searchActiveTask = "(task_id IS NULL OR task_id IN (SELECT id FROM public.api_tasks WHERE archived_at IS NULL))"
+
+// searchQuery builds the ranked query for one hit type. The tsquery is
+// bound as $1 and $2 is the headline options; filters follow.
+func searchQuery(kind string, in searchRequest) (string, []any) {
+	where := &whereBuilder{}
+	where.arg(in.Query)
+	where.arg(fmt.Sprintf("StartSel=%s, StopSel=%s, MaxFragments=2, MaxWords=20, MinWords=5", highlightStart, highlightStop))
+	where.add("search_vector @@ query")
+
+	var columns, table, text, agentColumn string
+	switch kind {
+	case "tasks":
+		table, agentColumn = "public.api_tasks", "assigned_to"
+		columns = "id::text, NULL::text, title, status, assigned_to, NULL::text, NULL::text, NULL::text"
+		text = "title || ' ' || description"
+		where.add("archived_at IS NULL")
+	case "comments":
+		table, agentColumn = "public.api_task_comments", "author_id"
+		columns = "id::text, task_id::text, NULL::text, NULL::text, NULL::text, NULL::text, author_type, author_id"
+		text = "body"
+		where.add(searchActiveTask)
+	case "logs":
+		table, agentColumn = "public.api_logs", "agent_id"
+		columns = "id::text, task_id::text, NULL::text, NULL::text, agent_id, level, NULL::text, NULL::text"
+		text = "message"
+		where.add(searchActiveTask)
+	}
+	if len(in.Agents) > 0 {
+		where.add(agentColumn + " = ANY(" + where.arg(in.Agents) + ")")
+	}
+	if in.After != nil {
+		where.add("created_at > " + where.arg(*in.After))
+	}
+	if in.Before != nil {
+		where.add("created_at < " + where.arg(*in.Before))
+	}
+
+	return `
+		SELECT ` + columns + `,
+			ts_rank(search_vector, query) AS search_rank,
+			ts_headline('` + searchConfig + `', ` + text + `, query, $2),
+			created_at
+		FROM ` + table + `, websearch_to_tsquery('` + searchConfig + `', $1) AS query
+		` + where.sql() + `
+		ORDER BY search_rank DESC, created_at DESC, id DESC
+		LIMIT ` + where.arg(in.Limit), where.args
+}
+
+func (s *server) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	in, err := parseSearchRequest(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	results := make(map[string][]searchHit, len(in.Types))
+	for _, kind := range in.Types {
+		hits, err := searchHits(ctx, tx, kind, in)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		results[kind] = hits
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"query": in.Query, "results": results})
+}
+
+func searchHits(ctx context.Context, tx *sql.Tx, kind string, in searchRequest) ([]searchHit, error) {
+	query, args := searchQuery(kind, in)
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hits := make([]searchHit, 0)
+	for rows.Next() {
+		var h searchHit
+		var authorType, authorID *string
+		if err := rows.Scan(&h.ID, &h.TaskID, &h.Title, &h.Status, &h.AgentID, &h.Level, &authorType, &authorID, &h.Rank, &h.Highlight, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		if authorType != nil && authorID != nil {
+			h.Author = &actor{Type: *authorType, ID: *authorID}
+		}
+		h.Highlight = renderHighlight(h.Highlight)
+		hits = append(hits, h)
+	}
+	return hits, rows.Err()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchRequest(t *testing.T) {
+	in, err := parseSearchRequest(url.Values{"q": {" timeout error "}, "type": {"logs"}, "agent": {"arga"}, "limit": {"500"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if in.Query != "timeout error" || len(in.Types) != 1 || in.Types[0] != "logs" || in.Limit != maxSearchLimit {
+		t.Fatalf("unexpected request: %+v", in)
+	}
+	if in, _ := parseSearchRequest(url.Values{"q": {"x"}}); len(in.Types) != len(searchTypes) {
+		t.Fatalf("expected every type by default, got %v", in.Types)
+	}
+
+	for _, q := range []url.Values{
+		{},
+		{"q": {strings.Repeat("a", maxSearchQuery+1)}},
+		{"q": {"x"}, "type": {"boards"}},
+		{"q": {"x"}, "created_after": {"yesterday"}},
+	} {
+		if _, err := parseSearchRequest(q); err == nil {
+			t.Fatalf("expected %v to be rejected", q)
+		}
+	}
+}
+
+func TestRenderHighlightEscapesText(t *testing.T) {
+	got := renderHighlight("<b>" + highlightStart + "timeout" + highlightStop + " & retry")
+	want := "&lt;b&gt;<mark>timeout</mark> &amp; retry"
+	if got != want {
+		t.Fatalf("renderHighlight = %q, want %q", got, want)
+	}
+}
+
+func TestSearchQueryAppliesAgentFilterPerType(t *testing.T) {
+	in := searchRequest{Query: "deploy", Agents: []string{"arga"}, Limit: 5}
+	for kind, column := range map[string]string{"tasks": "assigned_to", "comments": "author_id", "logs": "agent_id"} {
+		query, args := searchQuery(kind, in)
+		if !strings.Contains(query, column+" = ANY($3)") {
+			t.Fatalf("%s: expected an agent filter on %s, got %s", kind, column, query)
+		}
+		if len(args) != 4 || args[3] != 5 {
+			t.Fatalf("%s: unexpected args %#v", kind, args)
+		}
+		if kind != "tasks" && !strings.Contains(query, searchActiveTask) {
+			t.Fatalf("%s: expected hits on archived tasks to be left out, got %s", kind, query)
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// taskColumns is the projection shared by every query that returns a task;
+// keep it in sync with scanTask.
+const taskColumns = `id::text, column_id::text, parent_id::text, title, description, status, "order", rank, assigned_to, due_at, overdue_at, archived_at, version, created_at, updated_at, ` + taskLabelsColumn
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row rowScanner) (task, error) {
+	var t task
+	var labels []byte
+	if err := row.Scan(&t.ID, &t.ColumnID, &t.ParentID, &t.Title, &t.Description, &t.Status, &t.Order, &t.Rank, &t.AssignedTo, &t.DueAt, &t.OverdueAt, &t.ArchivedAt, &t.Version, &t.CreatedAt, &t.UpdatedAt, &labels); err != nil {
+		return t, err
+	}
+	err := json.Unmarshal(labels, &t.Labels)
+	return t, err
+}
+
+func isUUID(v string) bool {
+	return uuidPattern.MatchString(v)
+}
+
+// taskETag derives the entity tag of a task from its version, which every
+// mutation increments.
+func taskETag(t task) string {
+	return fmt.Sprintf(`"%d"`, t.Version)
+}
+
+// ifMatch reports whether an If-Match header value accepts etag. An absent
+// header or "*" matches any existing task. If-Match uses the strong
+// comparison (RFC 9110, 13.1.1), so weak tags never match.
+func ifMatch(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidate) == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTaskPrecondition enforces If-Match against the locked task. On a
+// mismatch it answers 412 with the current representation so the client can
+// resolve the conflict, and reports false.
+func checkTaskPrecondition(w http.ResponseWriter, r *http.Request, current task) bool {
+	if ifMatch(r.Header.Get("If-Match"), taskETag(current)) {
+		return true
+	}
+	w.Header().Set("ETag", taskETag(current))
+	writeJSON(w, http.StatusPreconditionFailed, map[string]any{
+		"error":   "task has been modified since it was read",
+		"current": current,
+	})
+	return false
+}
+
+// trimmedOrEmpty dereferences an optional string field, treating nil and
+// whitespace-only values alike so they can be stored as NULL.
+func trimmedOrEmpty(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return strings.TrimSpace(*v)
+}
+
+// taskFilter translates the query string of GET /api/tasks into SQL
+// conditions. List parameters accept comma-separated values.
+func taskFilter(q url.Values, wf *workflow) (*whereBuilder, error) {
+	where := &whereBuilder{}
+	if statuses := listParam(q, "status"); len(statuses) > 0 {
+		where.add("status = ANY(" + where.arg(statuses) + ")")
+	}
+	if columns := listParam(q, "columnIds", "column_id", "column"); len(columns) > 0 {
+		// Ids that are not UUIDs, such as the col-1 keys of older frontend
+		// builds, cannot name a column and simply match nothing.
+		ids := slices.DeleteFunc(columns, func(id string) bool { return !isUUID(id) })
+		where.add("column_id = ANY(" + where.arg(ids) + "::uuid[])")
+	}
+	if assignees := listParam(q, "assignee", "assigned_to"); len(assignees) > 0 {
+		where.add("assigned_to = ANY(" + where.arg(assignees) + ")")
+	}
+	if err := labelFilter(where, q); err != nil {
+		return nil, err
+	}
+	if err := parentFilter(where, q); err != nil {
+		return nil, err
+	}
+	if err := overdueFilter(where, q, wf); err != nil {
+		return nil, err
+	}
+	if err := archivedFilter(where, q); err != nil {
+		return nil, err
+	}
+	if err := where.addPageFilters(q, "created_at", "id"); err != nil {
+		return nil, err
+	}
+	return where, nil
+}
+
+func (s *server) taskItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getTask(w, r)
+	case http.MethodPatch:
+		s.updateTask(w, r)
+	case http.MethodDelete:
+		s.deleteTask(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) getTask(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	out, err := scanTask(s.db.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := attachProgress(ctx, s.db, s.taskWorkflow(), []*task{&out}); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := attachTimeSpent(ctx, s.db, []*task{&out}); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(out))
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) updateTask(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	// Every field is optional; only the ones present in the body are updated.
+	var in struct {
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		Status      *string `json:"status"`
+		ColumnID    *string `json:"column_id"`
+		Order       *int    `json:"order"`
+		AssignedTo  *string `json:"assigned_to"`
+		DueAt       *string `json:"due_at"`
+		ParentID    *string `json:"parent_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+
+	var sets []string
+	var args []any
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	if in.Title != nil {
+		title := strings.TrimSpace(*in.Title)
+		if title == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "title must not be empty"})
+			return
+		}
+		set("title", title)
+	}
+	if in.Description != nil {
+		set("description", strings.TrimSpace(*in.Description))
+	}
+	if in.Status != nil {
+		*in.Status = strings.TrimSpace(*in.Status)
+		if *in.Status == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "status must not be empty"})
+			return
+		}
+		set("status", *in.Status)
+	}
+	if in.ColumnID != nil && !isUUID(*in.ColumnID) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid column_id"})
+		return
+	}
+	if in.AssignedTo != nil {
+		// An empty assignee unassigns the task.
+		args = append(args, trimmedOrEmpty(in.AssignedTo))
+		sets = append(sets, fmt.Sprintf("assigned_to = NULLIF($%d, '')", len(args)))
+	}
+	if in.DueAt != nil {
+		// An empty due_at clears the due date; any change re-arms the sweeper.
+		dueAt, err := parseDueAt(*in.DueAt)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		set("due_at", dueAt)
+	}
+	if in.DueAt != nil || in.Status != nil && s.taskWorkflow().isFinal(*in.Status) {
+		// Finished work is no longer overdue.
+		sets = append(sets, "overdue_at = NULL")
+	}
+	if in.ParentID != nil {
+		// An empty parent_id turns a subtask back into a top-level task.
+		*in.ParentID = strings.TrimSpace(*in.ParentID)
+		if *in.ParentID != "" && !isUUID(*in.ParentID) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid parent_id"})
+			return
+		}
+		args = append(args, *in.ParentID)
+		sets = append(sets, fmt.Sprintf("parent_id = NULLIF($%d, '')::uuid", len(args)))
+	}
+	moving := in.ColumnID != nil || in.Order != nil
+	if len(sets) == 0 && !moving {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no fields to update"})
+		return
+	}
+	override, err := overrideWIPParam(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	target := ""
+	if in.ColumnID != nil {
+		target = *in.ColumnID
+	}
+	before, err := lockTask(ctx, tx, id, target)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if !checkTaskPrecondition(w, r, before) {
+		return
+	}
+	if in.Status != nil {
+		if err := s.taskWorkflow().checkTransition(before.Status, *in.Status); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		if err := checkBlockers(ctx, tx, s.taskWorkflow(), id, *in.Status); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+	if in.ParentID != nil && *in.ParentID != "" {
+		if err := checkParent(ctx, tx, id, *in.ParentID); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+	}
+
+	placed := false
+	if moving {
+		// column_id/order are a position on the board: route them through the
+		// same ranked placement as POST /api/tasks/{id}/move.
+		columnID := before.ColumnID
+		if in.ColumnID != nil {
+			columnID = in.ColumnID
+		}
+		sameColumn := before.ColumnID != nil && columnID != nil && *before.ColumnID == *columnID
+		switch {
+		case columnID == nil:
+			set(`"order"`, *in.Order)
+		case in.Order != nil || !sameColumn:
+			if err := placeTask(ctx, tx, before, *columnID, placement{Index: in.Order}); err != nil {
+				writeStoreError(w, err, "task not found")
+				return
+			}
+			placed = true
+		}
+	}
+
+	if len(sets) > 0 {
+		// placeTask has already advanced the version; each request moves
+		// the ETag on exactly once.
+		if !placed {
+			sets = append(sets, "version = version + 1", "updated_at = timezone('utc'::text, now())")
+		}
+		args = append(args, id)
+		res, err := tx.ExecContext(ctx, `
+			UPDATE public.api_tasks
+			SET `+strings.Join(sets, ", ")+`
+			WHERE id = $`+fmt.Sprint(len(args)), args...,
+		)
+		if err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+			return
+		}
+	}
+
+	out, err := scanTask(tx.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	by := actorFromRequest(r)
+	if err := enforceWIP(ctx, tx, by, &before, out, override); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := auditTask(ctx, tx, by, &before, &out); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(out))
+	writeJSON(w, http.StatusOK, out)
+}
+
+// deleteTask archives a task; ?permanent=true deletes it for good, including
+// a task that is already archived.
+func (s *server) deleteTask(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	permanent, err := permanentParam(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	lock := lockTask
+	if permanent {
+		lock = lockTaskIncludingArchived
+	}
+	current, err := lock(ctx, tx, id, "")
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if !checkTaskPrecondition(w, r, current) {
+		return
+	}
+
+	if !permanent {
+		out, err := setTaskArchived(ctx, tx, actorFromRequest(r), current, true)
+		if err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		if out.ColumnID != nil {
+			if err := renumberColumn(ctx, tx, *out.ColumnID); err != nil {
+				writeStoreError(w, err, "task not found")
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			writeStoreError(w, err, "task not found")
+			return
+		}
+		w.Header().Set("ETag", taskETag(out))
+		writeJSON(w, http.StatusOK, out)
+		return
+	}
+
+	out, err := scanTask(tx.QueryRowContext(ctx, `
+		DELETE FROM public.api_tasks
+		WHERE id = $1
+		RETURNING `+taskColumns, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := auditTask(ctx, tx, actorFromRequest(r), &out, nil); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
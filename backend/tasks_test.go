@@ -0,0 +1,127 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTaskItemRejectsUnknownMethod(t *testing.T) {
+	s := &server{}
+	r := httptest.NewRequest(http.MethodPut, "/api/tasks/abc", nil)
+	w := httptest.NewRecorder()
+
+	s.taskItem(w, r)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status 405, got %d", w.Code)
+	}
+}
+
+func TestWriteStoreErrorMapsStatuses(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"no rows", sql.ErrNoRows, http.StatusNotFound},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, http.StatusConflict},
+		{"foreign key violation", &pgconn.PgError{Code: "23503"}, http.StatusConflict},
+		{"invalid text", &pgconn.PgError{Code: "22P02"}, http.StatusBadRequest},
+		{"other", errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		writeStoreError(w, tc.err, "task not found")
+		if w.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.name, tc.want, w.Code)
+		}
+	}
+}
+
+func TestIsUUID(t *testing.T) {
+	if !isUUID("6f1c2a9e-3b4d-4c5e-8f70-123456789abc") {
+		t.Fatal("expected valid uuid to match")
+	}
+	for _, v := range []string{"", "col-1", "6f1c2a9e3b4d4c5e8f70123456789abc"} {
+		if isUUID(v) {
+			t.Fatalf("expected %q to be rejected", v)
+		}
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	etag := taskETag(task{Version: 3})
+	if etag != `"3"` {
+		t.Fatalf("unexpected etag %s", etag)
+	}
+	for _, header := range []string{"", "*", `"3"`, `"1", "3"`} {
+		if !ifMatch(header, etag) {
+			t.Fatalf("expected If-Match %q to match %s", header, etag)
+		}
+	}
+	for _, header := range []string{`"2"`, `W/"3"`, `W/"1", W/"3"`} {
+		if ifMatch(header, etag) {
+			t.Fatalf("expected If-Match %q to be rejected", header)
+		}
+	}
+}
+
+func TestCheckTaskPreconditionReturnsCurrentRepresentation(t *testing.T) {
+	current := task{ID: "t1", Title: "Current", Version: 4}
+	r := httptest.NewRequest(http.MethodPatch, "/api/tasks/t1", nil)
+	r.Header.Set("If-Match", `"3"`)
+	w := httptest.NewRecorder()
+
+	if checkTaskPrecondition(w, r, current) {
+		t.Fatal("expected precondition to fail")
+	}
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status 412, got %d", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != `"4"` {
+		t.Fatalf("expected current etag, got %q", got)
+	}
+	var payload struct {
+		Current task `json:"current"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("expected valid json response: %v", err)
+	}
+	if payload.Current.Title != "Current" || payload.Current.Version != 4 {
+		t.Fatalf("unexpected current task: %+v", payload.Current)
+	}
+}
+
+func TestUpdateTaskBumpsVersionOnce(t *testing.T) {
+	f, db := newFakeDB(t)
+	from, to := "00000000-0000-0000-0000-0000000000c1", "00000000-0000-0000-0000-0000000000c2"
+	current := task{ID: "5f0c7c1e-8f0a-4c43-9d6a-0d4f3c9b2a11", ColumnID: &from, Title: "Old", Status: "todo", Version: 2}
+	f.answer("FROM public.api_columns", []string{"id", "archived"},
+		[]driver.Value{from, false}, []driver.Value{to, false})
+	f.answer("SELECT id::text, rank", []string{"id", "rank"})
+	f.answer("FROM public.api_tasks", taskRowColumns, taskRow(current))
+
+	s := &server{db: db}
+	r := httptest.NewRequest(http.MethodPatch, "/api/tasks/"+current.ID, strings.NewReader(`{"column_id":"`+to+`","title":"New"}`))
+	r.SetPathValue("id", current.ID)
+	w := httptest.NewRecorder()
+	s.taskItem(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+
+	if bumps := f.find("version = version + 1"); len(bumps) != 1 || !strings.Contains(bumps[0].Query, "column_id = $1") {
+		t.Fatalf("expected only the placement to bump the version, got %+v", bumps)
+	}
+	if updates := f.find("SET title = $1"); len(updates) != 1 {
+		t.Fatalf("expected the title to be updated, got %+v", updates)
+	}
+}
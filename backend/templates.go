@@ -0,0 +1,804 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templateSchema creates board-scoped task templates. A template with a
+// recurrence rule is instantiated by the sweeper whenever next_run_at has
+// passed; templates without one are only instantiated on request.
+var templateSchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_task_templates (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		board_id UUID NOT NULL REFERENCES public.api_boards (id) ON DELETE CASCADE,
+		column_id UUID REFERENCES public.api_columns (id) ON DELETE SET NULL,
+		title TEXT NOT NULL,
+		description TEXT NOT NULL DEFAULT '',
+		status TEXT,
+		assigned_to TEXT,
+		checklist JSONB NOT NULL DEFAULT '[]'::jsonb,
+		label_ids UUID[] NOT NULL DEFAULT '{}',
+		recurrence TEXT,
+		timezone TEXT NOT NULL DEFAULT 'UTC',
+		enabled BOOLEAN NOT NULL DEFAULT true,
+		next_run_at TIMESTAMPTZ,
+		last_run_at TIMESTAMPTZ,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now()),
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_task_templates_board_idx ON public.api_task_templates (board_id)`,
+	`CREATE INDEX IF NOT EXISTS api_task_templates_next_run_idx ON public.api_task_templates (next_run_at) WHERE enabled AND next_run_at IS NOT NULL`,
+}
+
+const (
+	maxTemplateChecklist = 100
+	defaultPreviewCount  = 5
+	maxPreviewCount      = 50
+)
+
+// schedulerActor attributes tasks created from recurring templates.
+var schedulerActor = actor{Type: "system", ID: "scheduler"}
+
+type taskTemplate struct {
+	ID          string     `json:"id"`
+	BoardID     string     `json:"board_id"`
+	ColumnID    *string    `json:"column_id"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      *string    `json:"status"`
+	AssignedTo  *string    `json:"assigned_to"`
+	Checklist   []string   `json:"checklist"`
+	LabelIDs    []string   `json:"label_ids"`
+	Recurrence  *string    `json:"recurrence"`
+	Timezone    string     `json:"timezone"`
+	Enabled     bool       `json:"enabled"`
+	NextRunAt   *time.Time `json:"next_run_at"`
+	LastRunAt   *time.Time `json:"last_run_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+const templateColumns = `id::text, board_id::text, column_id::text, title, description, status, assigned_to,
+	checklist::text, to_json(label_ids::text[])::text, recurrence, timezone, enabled, next_run_at, last_run_at,
+	created_at, updated_at`
+
+func scanTemplate(row rowScanner) (taskTemplate, error) {
+	var t taskTemplate
+	var checklist, labels []byte
+	if err := row.Scan(&t.ID, &t.BoardID, &t.ColumnID, &t.Title, &t.Description, &t.Status, &t.AssignedTo,
+		&checklist, &labels, &t.Recurrence, &t.Timezone, &t.Enabled, &t.NextRunAt, &t.LastRunAt,
+		&t.CreatedAt, &t.UpdatedAt); err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(checklist, &t.Checklist); err != nil {
+		return t, err
+	}
+	err := json.Unmarshal(labels, &t.LabelIDs)
+	return t, err
+}
+
+// templateInput is the body of POST and PATCH; absent fields keep their
+// current value. Empty strings clear column_id, status, assigned_to and
+// recurrence.
+type templateInput struct {
+	ColumnID    *string   `json:"column_id"`
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Status      *string   `json:"status"`
+	AssignedTo  *string   `json:"assigned_to"`
+	Checklist   *[]string `json:"checklist"`
+	LabelIDs    *[]string `json:"label_ids"`
+	Recurrence  *string   `json:"recurrence"`
+	Timezone    *string   `json:"timezone"`
+	Enabled     *bool     `json:"enabled"`
+}
+
+func nullableString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// apply merges in into t and validates the result without touching the
+// database. It reports whether the schedule needs to be recomputed.
+func (in templateInput) apply(t *taskTemplate, wf *workflow) (bool, error) {
+	if in.ColumnID != nil {
+		t.ColumnID = nullableString(trimmedOrEmpty(in.ColumnID))
+	}
+	if in.Title != nil {
+		t.Title = strings.TrimSpace(*in.Title)
+	}
+	if in.Description != nil {
+		t.Description = strings.TrimSpace(*in.Description)
+	}
+	if in.Status != nil {
+		t.Status = nullableString(trimmedOrEmpty(in.Status))
+	}
+	if in.AssignedTo != nil {
+		t.AssignedTo = nullableString(trimmedOrEmpty(in.AssignedTo))
+	}
+	if in.Checklist != nil {
+		t.Checklist = make([]string, 0, len(*in.Checklist))
+		for _, item := range *in.Checklist {
+			title, err := parseChecklistTitle(item)
+			if err != nil {
+				return false, fmt.Errorf("checklist: %w", err)
+			}
+			t.Checklist = append(t.Checklist, title)
+		}
+	}
+	if in.LabelIDs != nil {
+		t.LabelIDs = make([]string, 0, len(*in.LabelIDs))
+		for _, id := range *in.LabelIDs {
+			id = strings.TrimSpace(id)
+			if !slices.Contains(t.LabelIDs, id) {
+				t.LabelIDs = append(t.LabelIDs, id)
+			}
+		}
+	}
+	if in.Recurrence != nil {
+		t.Recurrence = nullableString(trimmedOrEmpty(in.Recurrence))
+	}
+	if in.Timezone != nil {
+		t.Timezone = strings.TrimSpace(*in.Timezone)
+	}
+	if in.Enabled != nil {
+		t.Enabled = *in.Enabled
+	}
+
+	if t.Title == "" {
+		return false, errors.New("title is required")
+	}
+	if t.ColumnID != nil && !isUUID(*t.ColumnID) {
+		return false, errors.New("invalid column_id")
+	}
+	if t.Status != nil {
+		if err := wf.checkState(*t.Status); err != nil {
+			return false, err
+		}
+	}
+	if len(t.Checklist) > maxTemplateChecklist {
+		return false, fmt.Errorf("checklist must have at most %d items", maxTemplateChecklist)
+	}
+	for _, id := range t.LabelIDs {
+		if !isUUID(id) {
+			return false, fmt.Errorf("invalid label id %q", id)
+		}
+	}
+	if t.Timezone == "" {
+		t.Timezone = "UTC"
+	}
+	if _, err := t.schedule(); err != nil {
+		return false, err
+	}
+	return in.Recurrence != nil || in.Timezone != nil || in.Enabled != nil, nil
+}
+
+// schedule parses the template's recurrence rule; it is nil for templates
+// without one.
+func (t *taskTemplate) schedule() (*schedule, error) {
+	if t.Recurrence == nil {
+		if _, err := time.LoadLocation(t.Timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q", t.Timezone)
+		}
+		return nil, nil
+	}
+	return parseSchedule(*t.Recurrence, t.Timezone)
+}
+
+// nextRun is the first occurrence after now, or nil when the template is
+// disabled, not recurring, or its rule never fires. Missed occurrences are
+// not caught up.
+func (t *taskTemplate) nextRun(now time.Time) *time.Time {
+	sched, err := t.schedule()
+	if err != nil || sched == nil || !t.Enabled {
+		return nil
+	}
+	next := sched.next(now)
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// checkTemplateRefs verifies that the column and labels of a template belong
+// to its board.
+func checkTemplateRefs(ctx context.Context, tx *sql.Tx, t taskTemplate) error {
+	if t.ColumnID != nil {
+		var ok bool
+		if err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM public.api_columns WHERE id = $1 AND board_id = $2 AND archived_at IS NULL)`, *t.ColumnID, t.BoardID,
+		).Scan(&ok); err != nil {
+			return err
+		}
+		if !ok {
+			return newStatusError(http.StatusUnprocessableEntity, "column does not belong to the template's board")
+		}
+	}
+	if len(t.LabelIDs) > 0 {
+		var n int
+		if err := tx.QueryRowContext(ctx, `
+			SELECT count(*) FROM public.api_labels WHERE id = ANY($1::uuid[]) AND board_id = $2`, t.LabelIDs, t.BoardID,
+		).Scan(&n); err != nil {
+			return err
+		}
+		if n != len(t.LabelIDs) {
+			return newStatusError(http.StatusUnprocessableEntity, "every label must exist on the template's board")
+		}
+	}
+	return nil
+}
+
+// instantiateTemplate creates a task from t on its board, with its labels and
+// checklist, as one audited creation subject to the column's WIP limits.
+// Without a column, or when its column has been archived, the task goes to
+// the board's first column; labels deleted since the template was saved are
+// skipped.
+func (s *server) instantiateTemplate(ctx context.Context, tx *sql.Tx, by actor, t taskTemplate, override bool) (task, error) {
+	wf := s.taskWorkflow()
+	status := wf.Initial
+	if t.Status != nil {
+		status = *t.Status
+	}
+	if err := wf.checkState(status); err != nil {
+		return task{}, err
+	}
+
+	var columnID string
+	err := tx.QueryRowContext(ctx, `
+		SELECT id::text
+		FROM public.api_columns
+		WHERE board_id = $1 AND archived_at IS NULL
+		ORDER BY id = $2::uuid DESC NULLS LAST, "order" ASC, created_at ASC
+		LIMIT 1`, t.BoardID, t.ColumnID,
+	).Scan(&columnID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return task{}, newStatusError(http.StatusUnprocessableEntity, "template's board has no active columns")
+	}
+	if err != nil {
+		return task{}, err
+	}
+
+	var id string
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_tasks (title, description, status, assigned_to)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id::text`, t.Title, t.Description, status, t.AssignedTo,
+	).Scan(&id); err != nil {
+		return task{}, err
+	}
+	created, err := lockTask(ctx, tx, id, columnID)
+	if err != nil {
+		return task{}, err
+	}
+	if err := placeTask(ctx, tx, created, columnID, placement{}); err != nil {
+		return task{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO public.api_task_labels (task_id, label_id)
+		SELECT $1, id FROM public.api_labels WHERE id = ANY($2::uuid[]) AND board_id = $3`, id, t.LabelIDs, t.BoardID,
+	); err != nil {
+		return task{}, err
+	}
+	checklist, err := json.Marshal(t.Checklist)
+	if err != nil {
+		return task{}, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO public.api_checklist_items (task_id, title, position)
+		SELECT $1, item.title, item.ordinality - 1
+		FROM jsonb_array_elements_text($2::jsonb) WITH ORDINALITY AS item(title, ordinality)`, id, string(checklist),
+	); err != nil {
+		return task{}, err
+	}
+
+	out, err := scanTask(tx.QueryRowContext(ctx, `
+		SELECT `+taskColumns+`
+		FROM public.api_tasks
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		return task{}, err
+	}
+	if err := enforceWIP(ctx, tx, by, nil, out, override); err != nil {
+		return task{}, err
+	}
+	if err := auditTask(ctx, tx, by, nil, &out); err != nil {
+		return task{}, err
+	}
+	return out, nil
+}
+
+// runTemplates instantiates every recurring template whose next run has
+// passed. Like the other sweeps it is safe to run from several instances.
+func (s *server) runTemplates(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id::text
+		FROM public.api_task_templates
+		WHERE enabled AND next_run_at <= now()
+		ORDER BY next_run_at ASC
+		LIMIT 100`)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.runTemplate(ctx, id); err != nil {
+			log.Printf("run template %s: %v", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *server) runTemplate(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Another instance may be running it, or it may have been changed since
+	// it was listed.
+	t, err := scanTemplate(tx.QueryRowContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM public.api_task_templates
+		WHERE id = $1 AND enabled AND next_run_at <= now()
+		FOR UPDATE SKIP LOCKED`, id,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// A failing run is recorded and skipped so that a broken template does
+	// not retry on every sweep.
+	if _, err := tx.ExecContext(ctx, `SAVEPOINT template_run`); err != nil {
+		return err
+	}
+	// Nobody is there to retry a scheduled run, so it goes over a WIP limit
+	// and the override is logged for the scheduler.
+	out, runErr := s.instantiateTemplate(ctx, tx, schedulerActor, t, true)
+	if runErr != nil {
+		if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT template_run`); err != nil {
+			return err
+		}
+		message := fmt.Sprintf("Recurring template %q was skipped: %v", t.Title, runErr)
+		if err := insertLogEntry(ctx, tx, nil, "", "ERROR", message, map[string]any{
+			"source":      "scheduler",
+			"actions":     []string{"template_failed"},
+			"template_id": t.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	lastRun := &out.CreatedAt
+	if runErr != nil {
+		lastRun = t.LastRunAt
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE public.api_task_templates
+		SET next_run_at = $1, last_run_at = $2
+		WHERE id = $3`, t.nextRun(time.Now()), lastRun, t.ID,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *server) boardTemplates(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listTemplates(w, r)
+	case http.MethodPost:
+		s.createTemplate(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) templateItem(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.getTemplate(w, r)
+	case http.MethodPatch:
+		s.updateTemplate(w, r)
+	case http.MethodDelete:
+		s.deleteTemplate(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) listTemplates(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM public.api_task_templates
+		WHERE board_id = $1
+		ORDER BY lower(title) ASC, id ASC`, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]taskTemplate, 0)
+	for rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, t)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+func (s *server) createTemplate(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "board not found"})
+		return
+	}
+
+	var in templateInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	t := taskTemplate{BoardID: id, Checklist: []string{}, LabelIDs: []string{}, Timezone: "UTC", Enabled: true}
+	if _, err := in.apply(&t, s.taskWorkflow()); err != nil {
+		status, body := validationError(err)
+		writeJSON(w, status, body)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	if err := checkTemplateRefs(ctx, tx, t); err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+	checklist, err := json.Marshal(t.Checklist)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out, err := scanTemplate(tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_task_templates
+			(board_id, column_id, title, description, status, assigned_to, checklist, label_ids, recurrence, timezone, enabled, next_run_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::jsonb, $8::uuid[], $9, $10, $11, $12)
+		RETURNING `+templateColumns,
+		t.BoardID, t.ColumnID, t.Title, t.Description, t.Status, t.AssignedTo, string(checklist), t.LabelIDs,
+		t.Recurrence, t.Timezone, t.Enabled, t.nextRun(time.Now()),
+	))
+	if err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "board not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, out)
+}
+
+func (s *server) getTemplate(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "template not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	out, err := scanTemplate(s.db.QueryRowContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM public.api_task_templates
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) updateTemplate(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "template not found"})
+		return
+	}
+
+	var in templateInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	t, err := scanTemplate(tx.QueryRowContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM public.api_task_templates
+		WHERE id = $1
+		FOR UPDATE`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+	reschedule, err := in.apply(&t, s.taskWorkflow())
+	if err != nil {
+		status, body := validationError(err)
+		writeJSON(w, status, body)
+		return
+	}
+	if reschedule {
+		t.NextRunAt = t.nextRun(time.Now())
+	}
+	if err := checkTemplateRefs(ctx, tx, t); err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+	checklist, err := json.Marshal(t.Checklist)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out, err := scanTemplate(tx.QueryRowContext(ctx, `
+		UPDATE public.api_task_templates
+		SET column_id = $1, title = $2, description = $3, status = $4, assigned_to = $5, checklist = $6::jsonb,
+			label_ids = $7::uuid[], recurrence = $8, timezone = $9, enabled = $10, next_run_at = $11,
+			updated_at = timezone('utc'::text, now())
+		WHERE id = $12
+		RETURNING `+templateColumns,
+		t.ColumnID, t.Title, t.Description, t.Status, t.AssignedTo, string(checklist), t.LabelIDs,
+		t.Recurrence, t.Timezone, t.Enabled, t.NextRunAt, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) deleteTemplate(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "template not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	out, err := scanTemplate(s.db.QueryRowContext(ctx, `
+		DELETE FROM public.api_task_templates
+		WHERE id = $1
+		RETURNING `+templateColumns, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+// previewTemplate lists the next occurrences of a template's recurrence in
+// its time zone. ?recurrence= and ?timezone= override the saved rule so a
+// client can check an edit before saving it.
+func (s *server) previewTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "template not found"})
+		return
+	}
+	q := r.URL.Query()
+	count := defaultPreviewCount
+	if v := strings.TrimSpace(q.Get("count")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid count"})
+			return
+		}
+		count = min(n, maxPreviewCount)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	t, err := scanTemplate(s.db.QueryRowContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM public.api_task_templates
+		WHERE id = $1`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+	if q.Has("recurrence") {
+		t.Recurrence = nullableString(strings.TrimSpace(q.Get("recurrence")))
+	}
+	if v := strings.TrimSpace(q.Get("timezone")); v != "" {
+		t.Timezone = v
+	}
+	sched, err := t.schedule()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	occurrences := make([]time.Time, 0)
+	if sched != nil {
+		occurrences = sched.upcoming(time.Now(), count)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"template_id": t.ID,
+		"recurrence":  t.Recurrence,
+		"timezone":    t.Timezone,
+		"enabled":     t.Enabled,
+		"occurrences": occurrences,
+	})
+}
+
+// instantiateTemplateNow creates a task from a template on demand; it does
+// not affect the template's schedule.
+func (s *server) instantiateTemplateNow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "template not found"})
+		return
+	}
+	override, err := overrideWIPParam(r.URL.Query())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	t, err := scanTemplate(tx.QueryRowContext(ctx, `
+		SELECT `+templateColumns+`
+		FROM public.api_task_templates
+		WHERE id = $1
+		FOR SHARE`, id,
+	))
+	if err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+	out, err := s.instantiateTemplate(ctx, tx, actorFromRequest(r), t, override)
+	if err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "template not found")
+		return
+	}
+
+	w.Header().Set("ETag", taskETag(out))
+	writeJSON(w, http.StatusCreated, out)
+}
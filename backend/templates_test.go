@@ -0,0 +1,52 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTemplateInputApply(t *testing.T) {
+	title, recurrence := "  UAT checklist ", "0 9 * * mon"
+	checklist := []string{" login ", "logout"}
+	labels := []string{"3f2b7a4e-1c2d-4e5f-8a9b-0c1d2e3f4a5b", "3f2b7a4e-1c2d-4e5f-8a9b-0c1d2e3f4a5b"}
+	tpl := taskTemplate{Timezone: "UTC", Enabled: true}
+
+	reschedule, err := templateInput{Title: &title, Recurrence: &recurrence, Checklist: &checklist, LabelIDs: &labels}.apply(&tpl, defaultWorkflow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reschedule {
+		t.Fatal("expected a new recurrence to reschedule the template")
+	}
+	if tpl.Title != "UAT checklist" || !slices.Equal(tpl.Checklist, []string{"login", "logout"}) {
+		t.Fatalf("expected trimmed title and checklist, got %q %v", tpl.Title, tpl.Checklist)
+	}
+	if len(tpl.LabelIDs) != 1 {
+		t.Fatalf("expected duplicate labels to be dropped, got %v", tpl.LabelIDs)
+	}
+
+	next := tpl.nextRun(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	if next == nil || !next.Equal(time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected the next Monday 09:00, got %v", next)
+	}
+	tpl.Enabled = false
+	if next := tpl.nextRun(time.Now()); next != nil {
+		t.Fatalf("expected a disabled template not to be scheduled, got %v", next)
+	}
+}
+
+func TestTemplateInputApplyValidates(t *testing.T) {
+	empty, bad, status := " ", "every day", "shipped"
+	cases := []templateInput{
+		{Title: &empty},
+		{Recurrence: &bad},
+		{Status: &status},
+	}
+	for i, in := range cases {
+		tpl := taskTemplate{Title: "weekly sync", Timezone: "UTC"}
+		if _, err := in.apply(&tpl, defaultWorkflow); err == nil {
+			t.Errorf("case %d: expected a validation error", i)
+		}
+	}
+}
@@ -0,0 +1,659 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// timeSchema stores time spent on tasks. A timer is an entry without
+// ended_at; stopping it, or logging time by hand, fixes duration_seconds.
+var timeSchema = []string{
+	`CREATE TABLE IF NOT EXISTS public.api_time_entries (
+		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+		task_id UUID NOT NULL REFERENCES public.api_tasks (id) ON DELETE CASCADE,
+		actor_type TEXT NOT NULL,
+		actor_id TEXT NOT NULL,
+		source TEXT NOT NULL,
+		started_at TIMESTAMPTZ NOT NULL,
+		ended_at TIMESTAMPTZ,
+		duration_seconds INTEGER CHECK (duration_seconds >= 0),
+		note TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT timezone('utc'::text, now())
+	)`,
+	`CREATE INDEX IF NOT EXISTS api_time_entries_task_idx ON public.api_time_entries (task_id, started_at DESC)`,
+	`CREATE INDEX IF NOT EXISTS api_time_entries_actor_idx ON public.api_time_entries (actor_id, started_at)`,
+	// One running timer per actor and task.
+	`CREATE UNIQUE INDEX IF NOT EXISTS api_time_entries_running_idx ON public.api_time_entries (task_id, actor_type, actor_id) WHERE ended_at IS NULL`,
+}
+
+const (
+	// maxTimeEntry bounds a manual entry; longer stretches are logged per day.
+	maxTimeEntry      = 24 * time.Hour
+	maxTimeNoteLength = 1000
+	// maxReportDays bounds the range of GET /api/time/report.
+	maxReportDays = 366
+)
+
+// timeEntry is a stretch of work on a task. Entries of running timers have
+// no EndedAt and report the time elapsed so far as their duration.
+type timeEntry struct {
+	ID              string     `json:"id"`
+	TaskID          string     `json:"task_id"`
+	Actor           actor      `json:"actor"`
+	Source          string     `json:"source"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int64      `json:"duration_seconds"`
+	Running         bool       `json:"running"`
+	Note            string     `json:"note"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// entrySeconds is the duration of an entry, counting running timers up to
+// now. Timers start and stop at plain now(), so the arithmetic holds in any
+// session time zone.
+const entrySeconds = `COALESCE(duration_seconds, GREATEST(EXTRACT(EPOCH FROM now() - started_at), 0)::integer)`
+
+const timeEntryColumns = `id::text, task_id::text, actor_type, actor_id, source, started_at, ended_at, ` + entrySeconds + `, note, created_at`
+
+func scanTimeEntry(row rowScanner) (timeEntry, error) {
+	var e timeEntry
+	err := row.Scan(&e.ID, &e.TaskID, &e.Actor.Type, &e.Actor.ID, &e.Source, &e.StartedAt, &e.EndedAt, &e.DurationSeconds, &e.Note, &e.CreatedAt)
+	e.Running = e.EndedAt == nil
+	return e, err
+}
+
+// timeSpent is the total time logged on a task, included in task responses.
+type timeSpent struct {
+	Seconds int64 `json:"seconds"`
+	Entries int   `json:"entries"`
+	Running int   `json:"running"`
+}
+
+// attachTimeSpent fills in TimeSpent for tasks returned by list endpoints
+// with a single query.
+func attachTimeSpent(ctx context.Context, q queryer, tasks []*task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]string, len(tasks))
+	byID := make(map[string]*task, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+		byID[t.ID] = t
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT task_id::text, SUM(`+entrySeconds+`)::bigint, count(*), count(*) FILTER (WHERE ended_at IS NULL)
+		FROM public.api_time_entries
+		WHERE task_id = ANY($1::uuid[])
+		GROUP BY task_id`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for _, t := range tasks {
+		t.TimeSpent = &timeSpent{}
+	}
+	for rows.Next() {
+		var id string
+		var spent timeSpent
+		if err := rows.Scan(&id, &spent.Seconds, &spent.Entries, &spent.Running); err != nil {
+			return err
+		}
+		if t, ok := byID[id]; ok {
+			t.TimeSpent = &spent
+		}
+	}
+	return rows.Err()
+}
+
+// stopTimers ends every running timer on a task, e.g. when it is archived.
+func stopTimers(ctx context.Context, tx *sql.Tx, taskID string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE public.api_time_entries
+		SET ended_at = now(), duration_seconds = `+entrySeconds+`
+		WHERE task_id = $1 AND ended_at IS NULL`, taskID)
+	return err
+}
+
+// timeEntryInput is the body of POST /api/tasks/{id}/time. The duration is
+// given either in seconds or as a Go duration such as "1h30m"; started_at
+// defaults to the duration before now.
+type timeEntryInput struct {
+	DurationSeconds *int64  `json:"duration_seconds"`
+	Duration        *string `json:"duration"`
+	StartedAt       *string `json:"started_at"`
+	Note            string  `json:"note"`
+}
+
+func (in timeEntryInput) parse(now time.Time) (time.Time, time.Duration, string, error) {
+	var d time.Duration
+	switch {
+	case in.DurationSeconds != nil && in.Duration != nil:
+		return time.Time{}, 0, "", errors.New("give either duration_seconds or duration, not both")
+	case in.DurationSeconds != nil:
+		d = time.Duration(*in.DurationSeconds) * time.Second
+	case in.Duration != nil:
+		var err error
+		if d, err = time.ParseDuration(strings.TrimSpace(*in.Duration)); err != nil {
+			return time.Time{}, 0, "", fmt.Errorf("invalid duration %q", *in.Duration)
+		}
+	default:
+		return time.Time{}, 0, "", errors.New("duration_seconds or duration is required")
+	}
+	if d < time.Second || d > maxTimeEntry {
+		return time.Time{}, 0, "", fmt.Errorf("duration must be between 1s and %s", maxTimeEntry)
+	}
+	d = d.Truncate(time.Second)
+
+	start := now.Add(-d)
+	if in.StartedAt != nil && strings.TrimSpace(*in.StartedAt) != "" {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(*in.StartedAt))
+		if err != nil {
+			return time.Time{}, 0, "", fmt.Errorf("invalid started_at %q, expected RFC 3339", *in.StartedAt)
+		}
+		if t.After(now) {
+			return time.Time{}, 0, "", errors.New("started_at must not be in the future")
+		}
+		start = t.UTC()
+	}
+
+	note, err := parseTimeNote(in.Note)
+	return start, d, note, err
+}
+
+func parseTimeNote(v string) (string, error) {
+	note := strings.TrimSpace(v)
+	if len([]rune(note)) > maxTimeNoteLength {
+		return "", fmt.Errorf("note must be at most %d characters", maxTimeNoteLength)
+	}
+	return note, nil
+}
+
+// timeActor is the actor time is recorded for. Entries feed the per-agent
+// report, so anonymous requests are rejected.
+func timeActor(w http.ResponseWriter, r *http.Request) (actor, bool) {
+	by := actorFromRequest(r)
+	if by == anonymousActor {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "X-Agent-ID or X-User-ID header is required"})
+		return by, false
+	}
+	return by, true
+}
+
+func (s *server) taskTime(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.listTimeEntries(w, r)
+	case http.MethodPost:
+		s.logTime(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) taskTimeEntry(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodDelete:
+		s.deleteTimeEntry(w, r)
+	default:
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+func (s *server) listTimeEntries(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+timeEntryColumns+`
+		FROM public.api_time_entries
+		WHERE task_id = $1
+		ORDER BY started_at DESC, id DESC`, id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	items := make([]timeEntry, 0)
+	for rows.Next() {
+		e, err := scanTimeEntry(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		items = append(items, e)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, items)
+}
+
+// logTime records a manual time entry for the requesting actor.
+func (s *server) logTime(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	by, ok := timeActor(w, r)
+	if !ok {
+		return
+	}
+	var in timeEntryInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	start, d, note, err := in.parse(time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	taskTitle, err := lockTaskRow(ctx, tx, id)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	out, err := scanTimeEntry(tx.QueryRowContext(ctx, `
+		INSERT INTO public.api_time_entries (task_id, actor_type, actor_id, source, started_at, ended_at, duration_seconds, note)
+		VALUES ($1, $2, $3, 'manual', $4, $5, $6, $7)
+		RETURNING `+timeEntryColumns, id, by.Type, by.ID, start, start.Add(d), int64(d/time.Second), note,
+	))
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	message := fmt.Sprintf("%s logged %s on task %q", by.ID, formatDuration(d), taskTitle)
+	if err := insertActivityLog(ctx, tx, by, id, message, map[string]any{
+		"actions":          []string{"time_log"},
+		"time_entry_id":    out.ID,
+		"duration_seconds": out.DurationSeconds,
+	}); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, out)
+}
+
+// startTimer starts a timer on a task for the requesting actor. Each actor
+// runs at most one timer per task.
+func (s *server) startTimer(w http.ResponseWriter, r *http.Request) {
+	s.changeTimer(w, r, "timer_start")
+}
+
+// stopTimer stops the requesting actor's timer on a task, fixing its
+// duration.
+func (s *server) stopTimer(w http.ResponseWriter, r *http.Request) {
+	s.changeTimer(w, r, "timer_stop")
+}
+
+func (s *server) changeTimer(w http.ResponseWriter, r *http.Request, action string) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	id := r.PathValue("id")
+	if !isUUID(id) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "task not found"})
+		return
+	}
+	by, ok := timeActor(w, r)
+	if !ok {
+		return
+	}
+	var in struct {
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid json"})
+		return
+	}
+	note, err := parseTimeNote(in.Note)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	taskTitle, err := lockTaskRow(ctx, tx, id)
+	if err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	var out timeEntry
+	var message string
+	status := http.StatusOK
+	if action == "timer_start" {
+		out, err = scanTimeEntry(tx.QueryRowContext(ctx, `
+			INSERT INTO public.api_time_entries (task_id, actor_type, actor_id, source, started_at, note)
+			VALUES ($1, $2, $3, 'timer', now(), $4)
+			ON CONFLICT (task_id, actor_type, actor_id) WHERE ended_at IS NULL DO NOTHING
+			RETURNING `+timeEntryColumns, id, by.Type, by.ID, note,
+		))
+		if errors.Is(err, sql.ErrNoRows) {
+			err = newStatusError(http.StatusConflict, "a timer is already running on this task")
+		}
+		message = fmt.Sprintf("%s started a timer on task %q", by.ID, taskTitle)
+		status = http.StatusCreated
+	} else {
+		// A note given when stopping replaces the one given at the start.
+		out, err = scanTimeEntry(tx.QueryRowContext(ctx, `
+			UPDATE public.api_time_entries
+			SET ended_at = now(), duration_seconds = `+entrySeconds+`,
+				note = CASE WHEN $4 = '' THEN note ELSE $4 END
+			WHERE task_id = $1 AND actor_type = $2 AND actor_id = $3 AND ended_at IS NULL
+			RETURNING `+timeEntryColumns, id, by.Type, by.ID, note,
+		))
+		message = fmt.Sprintf("%s stopped the timer on task %q after %s", by.ID, taskTitle, formatDuration(time.Duration(out.DurationSeconds)*time.Second))
+	}
+	if err != nil {
+		writeStoreError(w, err, "no timer is running on this task")
+		return
+	}
+	if err := insertActivityLog(ctx, tx, by, id, message, map[string]any{
+		"actions":          []string{action},
+		"time_entry_id":    out.ID,
+		"duration_seconds": out.DurationSeconds,
+	}); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "task not found")
+		return
+	}
+
+	writeJSON(w, status, out)
+}
+
+// deleteTimeEntry removes an entry on behalf of the actor who recorded it;
+// deleting a running timer discards it.
+func (s *server) deleteTimeEntry(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	taskID, entryID := r.PathValue("id"), r.PathValue("entryID")
+	if !isUUID(taskID) || !isUUID(entryID) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "time entry not found"})
+		return
+	}
+	by, ok := timeActor(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer tx.Rollback()
+
+	taskTitle, err := lockTaskRow(ctx, tx, taskID)
+	if err != nil {
+		writeStoreError(w, err, "time entry not found")
+		return
+	}
+	current, err := scanTimeEntry(tx.QueryRowContext(ctx, `
+		SELECT `+timeEntryColumns+`
+		FROM public.api_time_entries
+		WHERE id = $1 AND task_id = $2`, entryID, taskID,
+	))
+	if err != nil {
+		writeStoreError(w, err, "time entry not found")
+		return
+	}
+	if current.Actor != by {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "only the actor who recorded this entry can delete it"})
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM public.api_time_entries WHERE id = $1`, entryID); err != nil {
+		writeStoreError(w, err, "time entry not found")
+		return
+	}
+	message := fmt.Sprintf("%s removed %s of logged time from task %q", by.ID, formatDuration(time.Duration(current.DurationSeconds)*time.Second), taskTitle)
+	if err := insertActivityLog(ctx, tx, by, taskID, message, map[string]any{
+		"actions":          []string{"time_delete"},
+		"time_entry_id":    current.ID,
+		"duration_seconds": current.DurationSeconds,
+	}); err != nil {
+		writeStoreError(w, err, "time entry not found")
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		writeStoreError(w, err, "time entry not found")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, current)
+}
+
+// timeReportRow is the time one actor logged on one day.
+type timeReportRow struct {
+	Agent   actor  `json:"agent"`
+	Day     string `json:"day"`
+	Seconds int64  `json:"seconds"`
+	Entries int    `json:"entries"`
+}
+
+// timeReportTotal is the time one actor logged over the whole range.
+type timeReportTotal struct {
+	Agent   actor `json:"agent"`
+	Seconds int64 `json:"seconds"`
+	Entries int   `json:"entries"`
+}
+
+type timeReport struct {
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	Timezone     string            `json:"timezone"`
+	Days         []timeReportRow   `json:"days"`
+	Agents       []timeReportTotal `json:"agents"`
+	TotalSeconds int64             `json:"total_seconds"`
+}
+
+// timeReportRange parses ?from=, ?to= and ?timezone= of the report. Days are
+// calendar dates in the timezone, both ends inclusive; the default is the
+// last seven days.
+func timeReportRange(q url.Values, now time.Time) (from, to time.Time, tz string, err error) {
+	tz = strings.TrimSpace(q.Get("timezone"))
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return from, to, tz, fmt.Errorf("unknown timezone %q", tz)
+	}
+	day := func(key string, def time.Time) (time.Time, error) {
+		v := strings.TrimSpace(q.Get(key))
+		if v == "" {
+			return def, nil
+		}
+		t, err := time.ParseInLocation(time.DateOnly, v, loc)
+		if err != nil {
+			return t, fmt.Errorf("invalid %s %q, expected YYYY-MM-DD", key, v)
+		}
+		return t, nil
+	}
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if to, err = day("to", today); err != nil {
+		return from, to, tz, err
+	}
+	if from, err = day("from", to.AddDate(0, 0, -6)); err != nil {
+		return from, to, tz, err
+	}
+	if to.Before(from) {
+		return from, to, tz, errors.New("to must not be before from")
+	}
+	if to.Sub(from) >= maxReportDays*24*time.Hour {
+		return from, to, tz, fmt.Errorf("the report covers at most %d days", maxReportDays)
+	}
+	return from, to, tz, nil
+}
+
+// summarizeTimeReport adds up the per-day rows into per-actor totals,
+// busiest first.
+func summarizeTimeReport(rows []timeReportRow) ([]timeReportTotal, int64) {
+	totals := make([]timeReportTotal, 0)
+	index := make(map[actor]int)
+	var sum int64
+	for _, row := range rows {
+		i, ok := index[row.Agent]
+		if !ok {
+			i = len(totals)
+			index[row.Agent] = i
+			totals = append(totals, timeReportTotal{Agent: row.Agent})
+		}
+		totals[i].Seconds += row.Seconds
+		totals[i].Entries += row.Entries
+		sum += row.Seconds
+	}
+	slices.SortStableFunc(totals, func(a, b timeReportTotal) int {
+		return cmp.Or(cmp.Compare(b.Seconds, a.Seconds), strings.Compare(a.Agent.ID, b.Agent.ID))
+	})
+	return totals, sum
+}
+
+// timeReport sums logged time per agent per day. Entries count towards the
+// day they started on; running timers count up to now. ?agent= and
+// ?board_id= narrow the report.
+func (s *server) timeReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+
+	q := r.URL.Query()
+	from, to, tz, err := timeReportRange(q, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	where := &whereBuilder{}
+	where.add("e.started_at >= " + where.arg(from))
+	where.add("e.started_at < " + where.arg(to.AddDate(0, 0, 1)))
+	if agents := listParam(q, "agent", "agent_id"); len(agents) > 0 {
+		where.add("e.actor_id = ANY(" + where.arg(agents) + ")")
+	}
+	if boardID := strings.TrimSpace(q.Get("board_id")); boardID != "" {
+		if !isUUID(boardID) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid board_id"})
+			return
+		}
+		where.add(`e.task_id IN (
+			SELECT t.id FROM public.api_tasks t JOIN public.api_columns c ON c.id = t.column_id
+			WHERE c.board_id = ` + where.arg(boardID) + `)`)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT e.actor_type, e.actor_id, to_char(e.started_at AT TIME ZONE `+where.arg(tz)+`, 'YYYY-MM-DD') AS day,
+			SUM(`+entrySeconds+`)::bigint, count(*)
+		FROM public.api_time_entries e
+		`+where.sql()+`
+		GROUP BY e.actor_type, e.actor_id, day
+		ORDER BY day ASC, e.actor_id ASC, e.actor_type ASC`, where.args...)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	out := timeReport{
+		From:     from.Format(time.DateOnly),
+		To:       to.Format(time.DateOnly),
+		Timezone: tz,
+		Days:     make([]timeReportRow, 0),
+	}
+	for rows.Next() {
+		var row timeReportRow
+		if err := rows.Scan(&row.Agent.Type, &row.Agent.ID, &row.Day, &row.Seconds, &row.Entries); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		out.Days = append(out.Days, row)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	out.Agents, out.TotalSeconds = summarizeTimeReport(out.Days)
+
+	writeJSON(w, http.StatusOK, out)
+}
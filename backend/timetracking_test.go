@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTimeEntryInputParse(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	duration, note := "1h30m", "  pairing "
+
+	start, d, gotNote, err := timeEntryInput{Duration: &duration, Note: note}.parse(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 90*time.Minute || !start.Equal(now.Add(-90*time.Minute)) || gotNote != "pairing" {
+		t.Fatalf("unexpected entry %s %s %q", start, d, gotNote)
+	}
+
+	seconds, startedAt := int64(600), "2026-10-15T09:00:00+07:00"
+	start, d, _, err = timeEntryInput{DurationSeconds: &seconds, StartedAt: &startedAt}.parse(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != 10*time.Minute || !start.Equal(time.Date(2026, 10, 15, 2, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected entry %s %s", start, d)
+	}
+}
+
+func TestTimeEntryInputParseValidates(t *testing.T) {
+	now := time.Now()
+	zero, long, bad, future := int64(0), "25h", "soon", now.Add(time.Hour).Format(time.RFC3339)
+	hour := "1h"
+	cases := []timeEntryInput{
+		{},
+		{DurationSeconds: &zero},
+		{Duration: &long},
+		{Duration: &bad},
+		{Duration: &hour, DurationSeconds: &zero},
+		{Duration: &hour, StartedAt: &future},
+	}
+	for i, in := range cases {
+		if _, _, _, err := in.parse(now); err == nil {
+			t.Errorf("case %d: expected a validation error", i)
+		}
+	}
+}
+
+func TestTimeReportRange(t *testing.T) {
+	now := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+
+	from, to, tz, err := timeReportRange(url.Values{"timezone": {"Asia/Jakarta"}}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 20:00 UTC is already the 17th in Jakarta.
+	if tz != "Asia/Jakarta" || to.Format(time.DateOnly) != "2026-10-17" || from.Format(time.DateOnly) != "2026-10-11" {
+		t.Fatalf("unexpected default range %s..%s in %s", from, to, tz)
+	}
+
+	for _, q := range []url.Values{
+		{"from": {"2026-10-10"}, "to": {"2026-10-01"}},
+		{"from": {"2024-01-01"}, "to": {"2026-01-01"}},
+		{"from": {"yesterday"}},
+		{"timezone": {"Mars/Olympus"}},
+	} {
+		if _, _, _, err := timeReportRange(q, now); err == nil {
+			t.Errorf("expected %v to be rejected", q)
+		}
+	}
+}
+
+func TestSummarizeTimeReport(t *testing.T) {
+	alice, bob := actor{Type: "agent", ID: "alice"}, actor{Type: "agent", ID: "bob"}
+	totals, sum := summarizeTimeReport([]timeReportRow{
+		{Agent: alice, Day: "2026-10-15", Seconds: 600, Entries: 1},
+		{Agent: bob, Day: "2026-10-15", Seconds: 3600, Entries: 2},
+		{Agent: alice, Day: "2026-10-16", Seconds: 1200, Entries: 1},
+	})
+	if sum != 5400 || len(totals) != 2 {
+		t.Fatalf("unexpected totals %+v (%d)", totals, sum)
+	}
+	if totals[0].Agent != bob || totals[1].Seconds != 1800 || totals[1].Entries != 2 {
+		t.Fatalf("expected per-agent totals busiest first, got %+v", totals)
+	}
+}
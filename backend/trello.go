@@ -0,0 +1,237 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trelloBoard is the subset of Trello's board JSON export that imports use.
+type trelloBoard struct {
+	Name       string            `json:"name"`
+	Members    []trelloMember    `json:"members"`
+	Lists      []trelloList      `json:"lists"`
+	Labels     []trelloLabel     `json:"labels"`
+	Cards      []trelloCard      `json:"cards"`
+	Checklists []trelloChecklist `json:"checklists"`
+	Actions    []trelloAction    `json:"actions"`
+}
+
+type trelloMember struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type trelloList struct {
+	ID     string  `json:"id"`
+	Name   string  `json:"name"`
+	Closed bool    `json:"closed"`
+	Pos    float64 `json:"pos"`
+}
+
+type trelloLabel struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type trelloCard struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Desc      string     `json:"desc"`
+	IDList    string     `json:"idList"`
+	Closed    bool       `json:"closed"`
+	Pos       float64    `json:"pos"`
+	Due       *time.Time `json:"due"`
+	IDLabels  []string   `json:"idLabels"`
+	IDMembers []string   `json:"idMembers"`
+}
+
+type trelloChecklist struct {
+	IDCard     string            `json:"idCard"`
+	Pos        float64           `json:"pos"`
+	CheckItems []trelloCheckItem `json:"checkItems"`
+}
+
+type trelloCheckItem struct {
+	Name  string  `json:"name"`
+	State string  `json:"state"`
+	Pos   float64 `json:"pos"`
+}
+
+// trelloAction is an entry of the board's activity; only commentCard
+// actions are imported.
+type trelloAction struct {
+	Type string    `json:"type"`
+	Date time.Time `json:"date"`
+	Data struct {
+		Text string `json:"text"`
+		Card struct {
+			ID string `json:"id"`
+		} `json:"card"`
+	} `json:"data"`
+	MemberCreator struct {
+		Username string `json:"username"`
+	} `json:"memberCreator"`
+}
+
+// byTrelloPos sorts Trello rows by their pos field, keeping export order for
+// ties.
+func byTrelloPos[T any](rows []T, pos func(T) float64) []T {
+	out := slices.Clone(rows)
+	slices.SortStableFunc(out, func(a, b T) int {
+		return cmp.Compare(pos(a), pos(b))
+	})
+	return out
+}
+
+// trelloColors maps Trello's named label colours onto hex values; the
+// _light and _dark variants use the same base colour.
+var trelloColors = map[string]string{
+	"green":  "#61bd4f",
+	"yellow": "#f2d600",
+	"orange": "#ff9f1a",
+	"red":    "#eb5a46",
+	"purple": "#c377e0",
+	"blue":   "#0079bf",
+	"sky":    "#00c2e0",
+	"lime":   "#51e898",
+	"pink":   "#ff78cb",
+	"black":  "#344563",
+}
+
+func trelloColor(name string) string {
+	base, _, _ := strings.Cut(name, "_")
+	if hex, ok := trelloColors[base]; ok {
+		return hex
+	}
+	return defaultLabelColor
+}
+
+// trelloCreatedAt recovers a card's creation time from its id, whose first
+// four bytes are a Unix timestamp.
+func trelloCreatedAt(id string) *time.Time {
+	if len(id) < 8 {
+		return nil
+	}
+	sec, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil || sec == 0 {
+		return nil
+	}
+	t := time.Unix(sec, 0).UTC()
+	return &t
+}
+
+// workflowStatusFor maps a Trello list name such as "In Progress" onto a
+// workflow state with a matching name, or the initial state.
+func workflowStatusFor(wf *workflow, listName string) string {
+	key := func(v string) string {
+		return strings.Map(func(r rune) rune {
+			if r == ' ' || r == '_' || r == '-' {
+				return -1
+			}
+			return r
+		}, strings.ToLower(v))
+	}
+	for _, state := range wf.States {
+		if key(state) == key(listName) {
+			return state
+		}
+	}
+	return wf.Initial
+}
+
+// toBoardExport maps lists to columns and cards to tasks, keeping Trello's
+// ordering; archived lists and cards are left out. Checklists are flattened
+// into the card's checklist, comments come from the commentCard actions
+// included in the export, and the first member of a card becomes its
+// assignee.
+func (tb *trelloBoard) toBoardExport(wf *workflow) *boardExport {
+	doc := &boardExport{
+		Format:  boardExportFormat,
+		Version: boardExportVersion,
+		Board:   exportedBoard{Name: tb.Name},
+	}
+
+	members := make(map[string]string, len(tb.Members))
+	for _, m := range tb.Members {
+		members[m.ID] = m.Username
+	}
+
+	lists := byTrelloPos(tb.Lists, func(l trelloList) float64 { return l.Pos })
+	statuses := make(map[string]string)
+	columnIndex := make(map[string]int)
+	for _, l := range lists {
+		if l.Closed {
+			continue
+		}
+		columnIndex[l.ID] = len(doc.Columns)
+		doc.Columns = append(doc.Columns, exportedColumn{Key: l.ID, Title: l.Name})
+		statuses[l.ID] = workflowStatusFor(wf, l.Name)
+	}
+
+	for _, l := range tb.Labels {
+		name := l.Name
+		if strings.TrimSpace(name) == "" && l.Color != "" {
+			// Trello allows colour-only labels.
+			name = strings.ReplaceAll(l.Color, "_", " ")
+		}
+		doc.Labels = append(doc.Labels, exportedLabel{Key: l.ID, Name: name, Color: trelloColor(l.Color)})
+	}
+
+	checklists := byTrelloPos(tb.Checklists, func(c trelloChecklist) float64 { return c.Pos })
+	items := make(map[string][]exportedChecklistItem)
+	for _, c := range checklists {
+		checkItems := byTrelloPos(c.CheckItems, func(i trelloCheckItem) float64 { return i.Pos })
+		for _, item := range checkItems {
+			items[c.IDCard] = append(items[c.IDCard], exportedChecklistItem{Title: item.Name, Done: item.State == "complete"})
+		}
+	}
+
+	// Trello lists actions newest first.
+	comments := make(map[string][]exportedComment)
+	for i := len(tb.Actions) - 1; i >= 0; i-- {
+		a := tb.Actions[i]
+		if a.Type != "commentCard" || a.Data.Card.ID == "" {
+			continue
+		}
+		date := a.Date
+		comments[a.Data.Card.ID] = append(comments[a.Data.Card.ID], exportedComment{
+			Author:    actor{Type: "user", ID: a.MemberCreator.Username},
+			Body:      a.Data.Text,
+			CreatedAt: &date,
+		})
+	}
+
+	cards := byTrelloPos(tb.Cards, func(c trelloCard) float64 { return c.Pos })
+	slices.SortStableFunc(cards, func(a, b trelloCard) int {
+		return cmp.Compare(columnIndex[a.IDList], columnIndex[b.IDList])
+	})
+	for _, c := range cards {
+		status, ok := statuses[c.IDList]
+		if c.Closed || !ok {
+			continue
+		}
+		t := exportedTask{
+			Key:         c.ID,
+			Column:      c.IDList,
+			Title:       c.Name,
+			Description: c.Desc,
+			Status:      status,
+			DueAt:       c.Due,
+			Labels:      c.IDLabels,
+			Checklist:   items[c.ID],
+			Comments:    comments[c.ID],
+			CreatedAt:   trelloCreatedAt(c.ID),
+		}
+		if len(c.IDMembers) > 0 {
+			if username := members[c.IDMembers[0]]; username != "" {
+				t.AssignedTo = &username
+			}
+		}
+		doc.Tasks = append(doc.Tasks, t)
+	}
+	return doc
+}
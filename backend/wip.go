@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// wipSchema adds Kanban work-in-progress limits to columns: wip_limit caps
+// the cards in a column, wip_limit_per_agent the cards any one assignee holds
+// there. NULL means unlimited.
+var wipSchema = []string{
+	`ALTER TABLE public.api_columns
+		ADD COLUMN IF NOT EXISTS wip_limit INTEGER CHECK (wip_limit > 0),
+		ADD COLUMN IF NOT EXISTS wip_limit_per_agent INTEGER CHECK (wip_limit_per_agent > 0)`,
+	`CREATE INDEX IF NOT EXISTS api_tasks_column_assignee_idx ON public.api_tasks (column_id, assigned_to) WHERE archived_at IS NULL`,
+}
+
+// columnLoad is the number of active cards in a column, reported next to its
+// limits on column listings.
+type columnLoad struct {
+	Tasks     int            `json:"tasks"`
+	ByAgent   map[string]int `json:"by_agent"`
+	OverLimit bool           `json:"over_limit"`
+}
+
+// attachColumnLoad fills in Load for columns returned by list endpoints with
+// a single query. A column is over its limit when it was lowered below the
+// current load or an override was used.
+func attachColumnLoad(ctx context.Context, q queryer, columns []*column) error {
+	if len(columns) == 0 {
+		return nil
+	}
+	ids := make([]string, len(columns))
+	byID := make(map[string]*column, len(columns))
+	for i, c := range columns {
+		ids[i] = c.ID
+		byID[c.ID] = c
+		c.Load = &columnLoad{ByAgent: map[string]int{}}
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT column_id::text, assigned_to, count(*)
+		FROM public.api_tasks
+		WHERE column_id = ANY($1::uuid[]) AND archived_at IS NULL
+		GROUP BY column_id, assigned_to`, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var agent *string
+		var n int
+		if err := rows.Scan(&id, &agent, &n); err != nil {
+			return err
+		}
+		c, ok := byID[id]
+		if !ok {
+			continue
+		}
+		c.Load.Tasks += n
+		if agent != nil {
+			c.Load.ByAgent[*agent] = n
+			if c.WIPLimitPerAgent != nil && n > *c.WIPLimitPerAgent {
+				c.Load.OverLimit = true
+			}
+		}
+	}
+	for _, c := range columns {
+		if c.WIPLimit != nil && c.Load.Tasks > *c.WIPLimit {
+			c.Load.OverLimit = true
+		}
+	}
+	return rows.Err()
+}
+
+func columnPointers(items []column) []*column {
+	out := make([]*column, len(items))
+	for i := range items {
+		out[i] = &items[i]
+	}
+	return out
+}
+
+// parseWIPLimit validates a limit from a column request body; 0 removes the
+// limit.
+func parseWIPLimit(name string, v *int) (*int, error) {
+	if v == nil || *v == 0 {
+		return nil, nil
+	}
+	if *v < 0 {
+		return nil, fmt.Errorf("%s must not be negative", name)
+	}
+	return v, nil
+}
+
+// overrideWIPParam reads ?override_wip= on task endpoints.
+func overrideWIPParam(q url.Values) (bool, error) {
+	v := strings.TrimSpace(q.Get("override_wip"))
+	if v == "" {
+		return false, nil
+	}
+	override, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid override_wip %q", v)
+	}
+	return override, nil
+}
+
+// wipViolation describes the limit a change would exceed. Load is the number
+// of cards before the change.
+type wipViolation struct {
+	ColumnID    string  `json:"column_id"`
+	ColumnTitle string  `json:"column_title"`
+	Scope       string  `json:"scope"`
+	AgentID     *string `json:"agent_id,omitempty"`
+	Limit       int     `json:"limit"`
+	Load        int     `json:"load"`
+}
+
+func (v wipViolation) message() string {
+	if v.Scope == "agent" {
+		return fmt.Sprintf("%s already holds %d of %d cards allowed per agent in column %q", *v.AgentID, v.Load, v.Limit, v.ColumnTitle)
+	}
+	return fmt.Sprintf("column %q is at its WIP limit of %d", v.ColumnTitle, v.Limit)
+}
+
+// wipChange reports which limits a change from before to after can break: a
+// card entering a column counts against both limits, a card changing hands
+// within one only against the per-agent limit. A nil before is a new task.
+func wipChange(before *task, after task) (entering, reassigned bool) {
+	if after.ColumnID == nil || after.ArchivedAt != nil {
+		return false, false
+	}
+	entering = before == nil || before.ColumnID == nil || *before.ColumnID != *after.ColumnID || before.ArchivedAt != nil
+	reassigned = after.AssignedTo != nil && (before == nil || before.AssignedTo == nil || *before.AssignedTo != *after.AssignedTo)
+	return entering, reassigned
+}
+
+// lockAgentWIP serialises the changes that add to an agent's cards in a
+// column until tx ends. Claims pick their task without locking its column,
+// so this lock is what orders them against each other and against task
+// updates; counts taken after it include every earlier change.
+func lockAgentWIP(ctx context.Context, tx *sql.Tx, columnID, agentID string) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('api_columns.wip_limit_per_agent:' || $1 || ':' || $2))`, columnID, agentID)
+	return err
+}
+
+// enforceWIP checks the column limits after a task was created, moved,
+// reassigned or restored inside tx, whose lockTask call holds the column
+// lock, so counting after the change is race free. Without override an
+// exceeded limit fails with 409; with it the change goes through and the
+// override is written to the activity log.
+func enforceWIP(ctx context.Context, tx *sql.Tx, by actor, before *task, after task, override bool) error {
+	entering, reassigned := wipChange(before, after)
+	if !entering && !reassigned {
+		return nil
+	}
+	if after.AssignedTo != nil {
+		if err := lockAgentWIP(ctx, tx, *after.ColumnID, *after.AssignedTo); err != nil {
+			return err
+		}
+	}
+
+	var title string
+	var limit, perAgent *int
+	var load, agentLoad int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT c.title, c.wip_limit, c.wip_limit_per_agent,
+			(SELECT count(*) FROM public.api_tasks t WHERE t.column_id = c.id AND t.archived_at IS NULL),
+			(SELECT count(*) FROM public.api_tasks t WHERE t.column_id = c.id AND t.archived_at IS NULL AND t.assigned_to = $2)
+		FROM public.api_columns c
+		WHERE c.id = $1`, *after.ColumnID, after.AssignedTo,
+	).Scan(&title, &limit, &perAgent, &load, &agentLoad); err != nil {
+		return err
+	}
+
+	v := wipViolation{ColumnID: *after.ColumnID, ColumnTitle: title}
+	switch {
+	case entering && limit != nil && load > *limit:
+		v.Scope, v.Limit, v.Load = "column", *limit, load-1
+	case (entering || reassigned) && after.AssignedTo != nil && perAgent != nil && agentLoad > *perAgent:
+		v.Scope, v.Limit, v.Load, v.AgentID = "agent", *perAgent, agentLoad-1, after.AssignedTo
+	default:
+		return nil
+	}
+
+	if !override {
+		return &statusError{status: http.StatusConflict, body: map[string]any{
+			"error": v.message(),
+			"wip":   v,
+			"hint":  "retry with override_wip=true to exceed the limit",
+		}}
+	}
+	message := fmt.Sprintf("%s overrode the WIP limit for task %q: %s", by.ID, after.Title, v.message())
+	return insertActivityLog(ctx, tx, by, after.ID, message, map[string]any{
+		"actions": []string{"wip_override"},
+		"wip":     v,
+	})
+}
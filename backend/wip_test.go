@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWIPChange(t *testing.T) {
+	todo, doing := "c1", "c2"
+	alice, bob := "alice", "bob"
+	current := task{ID: "t1", ColumnID: &todo, AssignedTo: &alice}
+
+	cases := []struct {
+		name                 string
+		before               *task
+		after                task
+		entering, reassigned bool
+	}{
+		{"created in a column", nil, task{ColumnID: &todo}, true, false},
+		{"created assigned", nil, task{ColumnID: &todo, AssignedTo: &alice}, true, true},
+		{"moved", &current, task{ColumnID: &doing, AssignedTo: &alice}, true, false},
+		{"reordered", &current, task{ColumnID: &todo, AssignedTo: &alice}, false, false},
+		{"handed over", &current, task{ColumnID: &todo, AssignedTo: &bob}, false, true},
+		{"unassigned", &current, task{ColumnID: &todo}, false, false},
+		{"no column", &current, task{}, false, false},
+	}
+	for _, tc := range cases {
+		entering, reassigned := wipChange(tc.before, tc.after)
+		if entering != tc.entering || reassigned != tc.reassigned {
+			t.Errorf("%s: got entering=%v reassigned=%v", tc.name, entering, reassigned)
+		}
+	}
+}
+
+func TestParseWIPLimit(t *testing.T) {
+	zero, three, negative := 0, 3, -1
+	if got, err := parseWIPLimit("wip_limit", &zero); err != nil || got != nil {
+		t.Fatalf("expected 0 to remove the limit, got %v %v", got, err)
+	}
+	if got, err := parseWIPLimit("wip_limit", &three); err != nil || got == nil || *got != 3 {
+		t.Fatalf("expected a limit of 3, got %v %v", got, err)
+	}
+	if _, err := parseWIPLimit("wip_limit", &negative); err == nil {
+		t.Fatal("expected a negative limit to be rejected")
+	}
+}
+
+func TestOverrideWIPParam(t *testing.T) {
+	if override, err := overrideWIPParam(url.Values{"override_wip": {"true"}}); err != nil || !override {
+		t.Fatalf("expected an override, got %v %v", override, err)
+	}
+	if _, err := overrideWIPParam(url.Values{"override_wip": {"please"}}); err == nil {
+		t.Fatal("expected an invalid override to be rejected")
+	}
+}
+
+func TestWIPViolationMessage(t *testing.T) {
+	agent := "alice"
+	v := wipViolation{ColumnTitle: "In Progress", Scope: "agent", AgentID: &agent, Limit: 2, Load: 2}
+	if got := v.message(); got != `alice already holds 2 of 2 cards allowed per agent in column "In Progress"` {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
+
+func TestImportEnforcesWIPLimits(t *testing.T) {
+	limit := 1
+	doc := &boardExport{
+		Board:   exportedBoard{Name: "Imported"},
+		Columns: []exportedColumn{{Key: "doing", Title: "Doing", WIPLimit: &limit}},
+		Tasks:   []exportedTask{{Key: "a", Column: "doing", Title: "A", Status: "todo"}},
+	}
+	for _, override := range []bool{false, true} {
+		f, db := newFakeDB(t)
+		f.answer("INSERT INTO public.api_boards", []string{"id", "name", "owner_id", "created_at"}, []driver.Value{"b1", "Imported", nil, time.Now()})
+		f.answer("INSERT INTO public.api_columns", []string{"id"}, []driver.Value{"c1"})
+		// The column already counts two cards, one above its limit.
+		f.answer("SELECT c.title, c.wip_limit", []string{"title", "wip_limit", "wip_limit_per_agent", "load", "agent_load"},
+			[]driver.Value{"Doing", int64(1), nil, int64(2), int64(0)})
+		f.answer("INSERT INTO public.api_tasks", []string{"id"}, []driver.Value{"t1"})
+		f.answer("FROM public.api_tasks", taskRowColumns, taskRow(task{ID: "t1", Title: "A", Status: "todo"}))
+
+		tx, err := db.BeginTx(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = insertBoardExport(context.Background(), tx, anonymousActor, doc, override)
+		tx.Rollback()
+
+		var se *statusError
+		if !override {
+			if !errors.As(err, &se) || se.status != http.StatusConflict {
+				t.Fatalf("expected a WIP conflict, got %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("override: %v", err)
+		}
+		overrides := 0
+		for _, l := range f.find("INSERT INTO public.api_logs") {
+			if strings.Contains(l.Args[4].(string), "wip_override") {
+				overrides++
+			}
+		}
+		if overrides != 1 {
+			t.Fatalf("expected the override to be logged once, got %d", overrides)
+		}
+	}
+}
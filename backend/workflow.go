@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+)
+
+// workflow is the task state machine enforced by the tasks API. It can be
+// replaced at startup with a JSON file named by TASK_WORKFLOW_FILE. Final
+// states mark finished work; when omitted, the last declared state is final.
+type workflow struct {
+	Initial     string              `json:"initial"`
+	States      []string            `json:"states"`
+	Final       []string            `json:"final,omitempty"`
+	Transitions map[string][]string `json:"transitions"`
+}
+
+var defaultWorkflow = &workflow{
+	Initial: "todo",
+	States:  []string{"todo", "in_progress", "review", "done"},
+	Final:   []string{"done"},
+	Transitions: map[string][]string{
+		"todo":        {"in_progress"},
+		"in_progress": {"todo", "review"},
+		"review":      {"in_progress", "done"},
+		"done":        {"in_progress"},
+	},
+}
+
+func loadWorkflow(path string) (*workflow, error) {
+	if path == "" {
+		return defaultWorkflow, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var wf workflow
+	if err := json.Unmarshal(data, &wf); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := wf.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &wf, nil
+}
+
+func (wf *workflow) validate() error {
+	if len(wf.States) == 0 {
+		return errors.New("workflow has no states")
+	}
+	if !wf.hasState(wf.Initial) {
+		return fmt.Errorf("initial state %q is not a declared state", wf.Initial)
+	}
+	for _, state := range wf.Final {
+		if !wf.hasState(state) {
+			return fmt.Errorf("final state %q is not a declared state", state)
+		}
+	}
+	for from, targets := range wf.Transitions {
+		if !wf.hasState(from) {
+			return fmt.Errorf("transition from unknown state %q", from)
+		}
+		for _, to := range targets {
+			if !wf.hasState(to) {
+				return fmt.Errorf("transition from %q to unknown state %q", from, to)
+			}
+		}
+	}
+	return nil
+}
+
+func (wf *workflow) hasState(state string) bool {
+	return slices.Contains(wf.States, state)
+}
+
+// finalStates lists the states in which a task counts as finished.
+func (wf *workflow) finalStates() []string {
+	if len(wf.Final) > 0 {
+		return wf.Final
+	}
+	return wf.States[len(wf.States)-1:]
+}
+
+func (wf *workflow) isFinal(state string) bool {
+	return slices.Contains(wf.finalStates(), state)
+}
+
+// next lists the states reachable from state in one step.
+func (wf *workflow) next(state string) []string {
+	if targets := wf.Transitions[state]; targets != nil {
+		return targets
+	}
+	return []string{}
+}
+
+// checkState rejects statuses that are not part of the workflow.
+func (wf *workflow) checkState(state string) error {
+	if wf.hasState(state) {
+		return nil
+	}
+	return &statusError{status: http.StatusUnprocessableEntity, body: map[string]any{
+		"error":  fmt.Sprintf("unknown status %q", state),
+		"states": wf.States,
+	}}
+}
+
+// checkTransition rejects moves the workflow does not allow. Staying in the
+// same state is always allowed, and tasks whose stored status predates the
+// workflow may move to any declared state.
+func (wf *workflow) checkTransition(from, to string) error {
+	if err := wf.checkState(to); err != nil {
+		return err
+	}
+	if from == to || !wf.hasState(from) || slices.Contains(wf.next(from), to) {
+		return nil
+	}
+	return &statusError{status: http.StatusUnprocessableEntity, body: map[string]any{
+		"error":   fmt.Sprintf("cannot move task from %q to %q", from, to),
+		"from":    from,
+		"to":      to,
+		"allowed": wf.next(from),
+	}}
+}
+
+func (s *server) taskWorkflow() *workflow {
+	if s.workflow == nil {
+		return defaultWorkflow
+	}
+	return s.workflow
+}
+
+func (s *server) workflowInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	wf := s.taskWorkflow()
+	transitions := make(map[string][]string, len(wf.States))
+	for _, state := range wf.States {
+		transitions[state] = wf.next(state)
+	}
+	writeJSON(w, http.StatusOK, workflow{
+		Initial:     wf.Initial,
+		States:      wf.States,
+		Final:       wf.finalStates(),
+		Transitions: transitions,
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkflowTransitions(t *testing.T) {
+	wf := defaultWorkflow
+
+	for _, tc := range []struct{ from, to string }{
+		{"todo", "in_progress"},
+		{"in_progress", "review"},
+		{"review", "in_progress"},
+		{"done", "done"},
+		{"legacy", "done"},
+	} {
+		if err := wf.checkTransition(tc.from, tc.to); err != nil {
+			t.Fatalf("expected %s -> %s to be allowed, got %v", tc.from, tc.to, err)
+		}
+	}
+
+	err := wf.checkTransition("todo", "done")
+	var statusErr *statusError
+	if !errors.As(err, &statusErr) || statusErr.status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for todo -> done, got %v", err)
+	}
+	if allowed, _ := statusErr.body["allowed"].([]string); len(allowed) != 1 || allowed[0] != "in_progress" {
+		t.Fatalf("expected allowed next states in payload, got %v", statusErr.body)
+	}
+
+	if err := wf.checkState("blocked"); err == nil {
+		t.Fatal("expected unknown status to be rejected")
+	}
+}
+
+func TestLoadWorkflowValidatesFile(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.json")
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(valid, []byte(`{"initial":"open","states":["open","closed"],"transitions":{"open":["closed"]}}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(invalid, []byte(`{"initial":"open","states":["open"],"transitions":{"open":["closed"]}}`), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	wf, err := loadWorkflow(valid)
+	if err != nil {
+		t.Fatalf("load valid workflow: %v", err)
+	}
+	if err := wf.checkTransition("closed", "open"); err == nil {
+		t.Fatal("expected closed -> open to be rejected by the custom workflow")
+	}
+	if _, err := loadWorkflow(invalid); err == nil {
+		t.Fatal("expected transition to an undeclared state to be rejected")
+	}
+}
+
+func TestWorkflowEndpoint(t *testing.T) {
+	s := &server{}
+	r := httptest.NewRequest(http.MethodGet, "/api/workflow", nil)
+	w := httptest.NewRecorder()
+
+	s.workflowInfo(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var payload workflow
+	if err := json.Unmarshal(w.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("expected valid json response: %v", err)
+	}
+	if payload.Initial != "todo" || len(payload.States) != 4 {
+		t.Fatalf("unexpected workflow payload: %+v", payload)
+	}
+	if got := payload.Transitions["review"]; len(got) != 2 {
+		t.Fatalf("expected review transitions, got %v", got)
+	}
+}
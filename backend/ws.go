@@ -0,0 +1,594 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsSendBuffer   = 64
+	wsWriteTimeout = 10 * time.Second
+	// wsPongTimeout drops connections that answer neither pings nor send
+	// anything themselves.
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 25 * time.Second
+	wsMaxMessage   = 4096
+	wsMaxTopics    = 32
+	// wsRouteBatch bounds the events routed with one load of their rows.
+	wsRouteBatch = 200
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     wsCheckOrigin,
+}
+
+// wsCheckOrigin admits the dashboard origins, same-origin pages and clients
+// without an Origin header, such as agents.
+func wsCheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if _, ok := allowedOrigins[origin]; ok || origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// wsActor identifies a WebSocket client. Browsers cannot set headers on the
+// handshake, so agent_id and user_id query parameters are accepted too.
+func wsActor(r *http.Request) actor {
+	if by := actorFromRequest(r); by != anonymousActor {
+		return by
+	}
+	q := r.URL.Query()
+	if id := strings.TrimSpace(q.Get("agent_id")); id != "" {
+		return actor{Type: "agent", ID: id}
+	}
+	if id := strings.TrimSpace(q.Get("user_id")); id != "" {
+		return actor{Type: "user", ID: id}
+	}
+	return anonymousActor
+}
+
+// wsMessage is the envelope of every frame in either direction. Clients send
+// subscribe, unsubscribe and ping, and get subscribed, unsubscribed, pong or
+// error back with their ID echoed. The server pushes welcome, event,
+// presence and resync messages; after resync clients should reload what
+// they show, as events may have been missed.
+type wsMessage struct {
+	Type    string       `json:"type"`
+	ID      string       `json:"id,omitempty"`
+	Topic   string       `json:"topic,omitempty"`
+	Event   *changeEvent `json:"event,omitempty"`
+	Task    *task        `json:"task,omitempty"`
+	Log     *logEntry    `json:"log,omitempty"`
+	Actor   *actor       `json:"actor,omitempty"`
+	Viewers []actor      `json:"viewers,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// parseTopic validates a topic: board:{id} carries the board's task
+// changes, logs every log row and agents the log rows of agents and changes
+// to assigned tasks. It returns the board id of board topics.
+func parseTopic(topic string) (string, error) {
+	switch topic {
+	case "logs", "agents":
+		return "", nil
+	}
+	if id, ok := strings.CutPrefix(topic, "board:"); ok {
+		if !isUUID(id) {
+			return "", errors.New("board not found")
+		}
+		return id, nil
+	}
+	return "", fmt.Errorf("unknown topic %q, expected board:{id}, logs or agents", topic)
+}
+
+// eventTopics lists the topics an event is delivered to.
+func eventTopics(e changeEvent) []string {
+	var topics []string
+	switch e.Table {
+	case "tasks":
+		if e.BoardID != nil {
+			topics = append(topics, "board:"+*e.BoardID)
+		}
+		if e.OldBoardID != nil && (e.BoardID == nil || *e.OldBoardID != *e.BoardID) {
+			topics = append(topics, "board:"+*e.OldBoardID)
+		}
+	case "logs":
+		topics = append(topics, "logs")
+	}
+	if e.AgentID != nil {
+		topics = append(topics, "agents")
+	}
+	return topics
+}
+
+// wsConn is one WebSocket client. Messages are queued on send and written by
+// a single goroutine; a client that lets the queue fill up is disconnected
+// rather than slowing down everyone else.
+type wsConn struct {
+	actor actor
+	send  chan []byte
+	done  chan struct{}
+	once  sync.Once
+	// closeCode and closeText are set before done is closed.
+	closeCode int
+	closeText string
+	// topics is guarded by the hub's mutex.
+	topics map[string]struct{}
+}
+
+func newWSConn(by actor) *wsConn {
+	return &wsConn{
+		actor:  by,
+		send:   make(chan []byte, wsSendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+}
+
+func (c *wsConn) close(code int, text string) {
+	c.once.Do(func() {
+		c.closeCode, c.closeText = code, text
+		close(c.done)
+	})
+}
+
+// enqueue queues data without blocking.
+func (c *wsConn) enqueue(data []byte) {
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	select {
+	case c.send <- data:
+	default:
+		c.close(websocket.CloseTryAgainLater, "send buffer full")
+	}
+}
+
+func (c *wsConn) sendMessage(msg wsMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("websocket: encode %s message: %v", msg.Type, err)
+		return
+	}
+	c.enqueue(data)
+}
+
+// writeLoop is the only writer of conn. It closes conn when c is closed or
+// a write fails, which also ends the read loop.
+func (c *wsConn) writeLoop(conn *websocket.Conn) {
+	defer conn.Close()
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case data := <-c.send:
+			conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-c.done:
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, c.closeText), time.Now().Add(wsWriteTimeout))
+			return
+		}
+	}
+}
+
+// wsHub routes events to WebSocket connections by topic. Presence is the set
+// of actors subscribed to a topic on this instance.
+type wsHub struct {
+	mu     sync.Mutex
+	conns  map[*wsConn]struct{}
+	topics map[string]map[*wsConn]struct{}
+}
+
+func newWSHub() *wsHub {
+	return &wsHub{conns: make(map[*wsConn]struct{}), topics: make(map[string]map[*wsConn]struct{})}
+}
+
+func (h *wsHub) add(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conns[c] = struct{}{}
+}
+
+// remove unregisters c and updates the presence of the topics it watched.
+func (h *wsHub) remove(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.conns, c)
+	for topic := range c.topics {
+		h.leaveLocked(c, topic)
+		h.presenceLocked(topic)
+	}
+}
+
+func (h *wsHub) join(c *wsConn, topic string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := c.topics[topic]; !ok && len(c.topics) >= wsMaxTopics {
+		return fmt.Errorf("at most %d topics per connection", wsMaxTopics)
+	}
+	c.topics[topic] = struct{}{}
+	if h.topics[topic] == nil {
+		h.topics[topic] = make(map[*wsConn]struct{})
+	}
+	h.topics[topic][c] = struct{}{}
+	return nil
+}
+
+func (h *wsHub) leave(c *wsConn, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.leaveLocked(c, topic)
+}
+
+func (h *wsHub) leaveLocked(c *wsConn, topic string) {
+	delete(c.topics, topic)
+	delete(h.topics[topic], c)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// viewers lists the actors subscribed to topic, each once.
+func (h *wsHub) viewers(topic string) []actor {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.viewersLocked(topic)
+}
+
+func (h *wsHub) viewersLocked(topic string) []actor {
+	out := make([]actor, 0, len(h.topics[topic]))
+	for c := range h.topics[topic] {
+		out = append(out, c.actor)
+	}
+	slices.SortFunc(out, func(a, b actor) int {
+		return cmp.Or(cmp.Compare(a.Type, b.Type), cmp.Compare(a.ID, b.ID))
+	})
+	return slices.Compact(out)
+}
+
+// watched reports whether anyone is subscribed to one of topics.
+func (h *wsHub) watched(topics []string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		if len(h.topics[topic]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *wsHub) broadcast(topic string, msg wsMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.broadcastLocked(topic, msg)
+}
+
+func (h *wsHub) broadcastLocked(topic string, msg wsMessage) {
+	if len(h.topics[topic]) == 0 {
+		return
+	}
+	msg.Topic = topic
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("websocket: encode %s message: %v", msg.Type, err)
+		return
+	}
+	for c := range h.topics[topic] {
+		c.enqueue(data)
+	}
+}
+
+func (h *wsHub) broadcastAll(msg wsMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("websocket: encode %s message: %v", msg.Type, err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for c := range h.conns {
+		c.enqueue(data)
+	}
+}
+
+func (h *wsHub) presence(topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.presenceLocked(topic)
+}
+
+func (h *wsHub) presenceLocked(topic string) {
+	h.broadcastLocked(topic, wsMessage{Type: "presence", Viewers: h.viewersLocked(topic)})
+}
+
+// routeRealtime forwards the realtime hub's events to WebSocket subscribers
+// until ctx is cancelled. Events queued meanwhile are routed together, so a
+// burst of changes costs one query per table rather than one per event.
+func (s *server) routeRealtime(ctx context.Context) {
+	sub := s.hub.subscribe()
+	defer func() { sub.Close() }()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C:
+			var events []changeEvent
+			if ok {
+				events, ok = queuedEvents(sub, e)
+				s.routeEvents(ctx, events)
+			}
+			if !ok {
+				// The router fell behind, so clients may have missed events.
+				sub = s.hub.subscribe()
+				s.sockets.broadcastAll(wsMessage{Type: "resync"})
+			}
+		}
+	}
+}
+
+// queuedEvents returns first and up to wsRouteBatch events already queued on
+// sub, and whether sub is still open.
+func queuedEvents(sub *subscription, first changeEvent) ([]changeEvent, bool) {
+	events := []changeEvent{first}
+	for len(events) < wsRouteBatch {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return events, false
+			}
+			events = append(events, e)
+		default:
+			return events, true
+		}
+	}
+	return events, true
+}
+
+// coalesceEvents keeps the last event of each row, in the order rows last
+// changed. A task keeps the board it left first, so a move followed by
+// more changes still reaches the source board.
+func coalesceEvents(events []changeEvent) []changeEvent {
+	type key struct{ table, id string }
+	last := make(map[key]int)
+	var out []changeEvent
+	for _, e := range events {
+		if e.Op == "resync" {
+			out = append(out, e)
+			continue
+		}
+		k := key{e.Table, e.ID}
+		if i, ok := last[k]; ok {
+			prev := out[i]
+			e.OldBoardID, e.OldColumnID = prev.OldBoardID, prev.OldColumnID
+			if prev.Op == "insert" && e.Op != "delete" {
+				e.Op = "insert"
+			}
+			out[i].Op = ""
+		}
+		last[k] = len(out)
+		out = append(out, e)
+	}
+	return slices.DeleteFunc(out, func(e changeEvent) bool { return e.Op == "" })
+}
+
+// routeEvents delivers events to the topics watching them, with the changed
+// rows loaded in one query per table for all subscribers.
+func (s *server) routeEvents(ctx context.Context, events []changeEvent) {
+	var routed []changeEvent
+	var taskIDs, logIDs []string
+	for _, e := range coalesceEvents(events) {
+		if e.Op == "resync" {
+			s.sockets.broadcastAll(wsMessage{Type: "resync"})
+			continue
+		}
+		if !s.sockets.watched(eventTopics(e)) {
+			continue
+		}
+		routed = append(routed, e)
+		switch {
+		case e.Table == "tasks" && e.Op != "delete":
+			taskIDs = append(taskIDs, e.ID)
+		case e.Table == "logs":
+			logIDs = append(logIDs, e.ID)
+		}
+	}
+	if len(routed) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// A row that is gone or unreadable is left out; the event alone still
+	// tells clients what changed.
+	tasks := make(map[string]*task)
+	if len(taskIDs) > 0 {
+		if err := eachRow(ctx, s.db, func(rows *sql.Rows) error {
+			t, err := scanTask(rows)
+			if err != nil {
+				return err
+			}
+			tasks[t.ID] = &t
+			return nil
+		}, `SELECT `+taskColumns+`
+			FROM public.api_tasks
+			WHERE id = ANY($1::uuid[])`, taskIDs); err != nil {
+			log.Printf("websocket: load %d tasks: %v", len(taskIDs), err)
+		}
+	}
+	logs := make(map[string]*logEntry)
+	if len(logIDs) > 0 {
+		if err := eachRow(ctx, s.db, func(rows *sql.Rows) error {
+			l, err := scanLog(rows)
+			if err != nil {
+				return err
+			}
+			logs[l.ID] = &l
+			return nil
+		}, `SELECT `+logColumns+`
+			FROM public.api_logs
+			WHERE id = ANY($1::uuid[])`, logIDs); err != nil {
+			log.Printf("websocket: load %d logs: %v", len(logIDs), err)
+		}
+	}
+
+	for _, e := range routed {
+		msg := wsMessage{Type: "event", Event: &e}
+		switch e.Table {
+		case "tasks":
+			msg.Task = tasks[e.ID]
+		case "logs":
+			msg.Log = logs[e.ID]
+		}
+		for _, topic := range eventTopics(e) {
+			s.sockets.broadcast(topic, msg)
+		}
+	}
+}
+
+// webSocket serves /api/ws. Clients identify themselves on connect and then
+// subscribe to topics; see wsMessage for the protocol.
+func (s *server) webSocket(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	if s.db == nil || s.sockets == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "database not configured"})
+		return
+	}
+	by := wsActor(r)
+	if by == anonymousActor {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "X-Agent-ID or X-User-ID header, or agent_id or user_id parameter, is required"})
+		return
+	}
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already replied.
+		return
+	}
+	s.serveWS(conn, by)
+}
+
+// serveWS runs one connection until it closes.
+func (s *server) serveWS(conn *websocket.Conn, by actor) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := newWSConn(by)
+	s.sockets.add(c)
+	defer s.sockets.remove(c)
+	go c.writeLoop(conn)
+	go func() {
+		select {
+		case <-s.closing:
+			c.close(websocket.CloseGoingAway, "server shutting down")
+		case <-ctx.Done():
+		}
+	}()
+	c.sendMessage(wsMessage{Type: "welcome", Actor: &by})
+
+	conn.SetReadLimit(wsMaxMessage)
+	alive := func() error { return conn.SetReadDeadline(time.Now().Add(wsPongTimeout)) }
+	alive()
+	conn.SetPongHandler(func(string) error { return alive() })
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			c.close(websocket.CloseNormalClosure, "")
+			return
+		}
+		alive()
+		var in wsMessage
+		if err := json.Unmarshal(data, &in); err != nil {
+			c.sendMessage(wsMessage{Type: "error", Error: "invalid message"})
+			continue
+		}
+		s.wsCommand(ctx, c, in)
+	}
+}
+
+// wsCommand handles one client message.
+func (s *server) wsCommand(ctx context.Context, c *wsConn, in wsMessage) {
+	reply := wsMessage{ID: in.ID, Topic: in.Topic}
+	fail := func(err error) {
+		reply.Type, reply.Error = "error", err.Error()
+		c.sendMessage(reply)
+	}
+	switch in.Type {
+	case "ping":
+		reply.Type = "pong"
+		c.sendMessage(reply)
+	case "subscribe":
+		boardID, err := parseTopic(in.Topic)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if boardID != "" {
+			if err := s.checkBoard(ctx, boardID); err != nil {
+				fail(err)
+				return
+			}
+		}
+		if err := s.sockets.join(c, in.Topic); err != nil {
+			fail(err)
+			return
+		}
+		reply.Type, reply.Viewers = "subscribed", s.sockets.viewers(in.Topic)
+		c.sendMessage(reply)
+		s.sockets.presence(in.Topic)
+	case "unsubscribe":
+		s.sockets.leave(c, in.Topic)
+		reply.Type = "unsubscribed"
+		c.sendMessage(reply)
+		s.sockets.presence(in.Topic)
+	default:
+		fail(fmt.Errorf("unknown message type %q", in.Type))
+	}
+}
+
+func (s *server) checkBoard(ctx context.Context, id string) error {
+	if s.db == nil {
+		return errors.New("database not configured")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM public.api_boards WHERE id = $1)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("board not found")
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseTopic(t *testing.T) {
+	for _, topic := range []string{"logs", "agents", "board:00000000-0000-0000-0000-000000000001"} {
+		if _, err := parseTopic(topic); err != nil {
+			t.Errorf("expected %q to be accepted: %v", topic, err)
+		}
+	}
+	for _, topic := range []string{"", "board:", "board:nope", "tasks"} {
+		if _, err := parseTopic(topic); err == nil {
+			t.Errorf("expected %q to be rejected", topic)
+		}
+	}
+}
+
+func TestEventTopics(t *testing.T) {
+	board, agent := "b1", "arga"
+	got := eventTopics(changeEvent{Table: "tasks", BoardID: &board, AgentID: &agent})
+	if strings.Join(got, ",") != "board:b1,agents" {
+		t.Fatalf("unexpected topics %v", got)
+	}
+	if got := eventTopics(changeEvent{Table: "logs"}); strings.Join(got, ",") != "logs" {
+		t.Fatalf("unexpected topics %v", got)
+	}
+	from := "b0"
+	if got := eventTopics(changeEvent{Table: "tasks", BoardID: &board, OldBoardID: &from}); strings.Join(got, ",") != "board:b1,board:b0" {
+		t.Fatalf("expected a move to reach both boards, got %v", got)
+	}
+}
+
+func TestRouteEventsLoadsEachTableOnce(t *testing.T) {
+	f, db := newFakeDB(t)
+	s := &server{db: db, sockets: newWSHub()}
+	from, to, other := "b0", "b1", "b2"
+	column := "c1"
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	f.answer("FROM public.api_tasks", taskRowColumns,
+		taskRow(task{ID: "t1", ColumnID: &column, Title: "Moved", Status: "todo", Version: 3, CreatedAt: at, UpdatedAt: at}))
+	f.answer("FROM public.api_logs",
+		[]string{"id", "agent_id", "task_id", "level", "message", "metadata", "source", "session_id", "created_at"},
+		[]driver.Value{"l1", nil, nil, "INFO", "hello", []byte("null"), nil, nil, at})
+
+	source, target, logs := newWSConn(actor{Type: "user", ID: "martha"}), newWSConn(actor{Type: "user", ID: "ada"}), newWSConn(actor{Type: "agent", ID: "arga"})
+	for conn, topic := range map[*wsConn]string{source: "board:b0", target: "board:b1", logs: "logs"} {
+		s.sockets.add(conn)
+		if err := s.sockets.join(conn, topic); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.routeEvents(context.Background(), []changeEvent{
+		{Table: "tasks", Op: "update", ID: "t1", BoardID: &to, OldBoardID: &from},
+		{Table: "logs", Op: "insert", ID: "l1"},
+		{Table: "tasks", Op: "update", ID: "t1", BoardID: &to},
+		{Table: "tasks", Op: "update", ID: "t2", BoardID: &other},
+	})
+
+	if got := f.find("FROM public.api_tasks"); len(got) != 1 || !slices.Equal(got[0].Args[0].([]string), []string{"t1"}) {
+		t.Fatalf("expected one task query for the watched task, got %+v", got)
+	}
+	if got := f.find("FROM public.api_logs"); len(got) != 1 {
+		t.Fatalf("expected one log query, got %+v", got)
+	}
+	for name, conn := range map[string]*wsConn{"source": source, "target": target} {
+		var msg wsMessage
+		if err := json.Unmarshal(<-conn.send, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Task == nil || msg.Task.Title != "Moved" || msg.Event.OldBoardID == nil || len(conn.send) != 0 {
+			t.Fatalf("expected the %s board to get the move once with the task, got %+v", name, msg)
+		}
+	}
+	if msg := string(<-logs.send); !strings.Contains(msg, `"message":"hello"`) {
+		t.Fatalf("expected the log row, got %s", msg)
+	}
+}
+
+func TestWSActor(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/ws?user_id=martha", nil)
+	if got := wsActor(r); got != (actor{Type: "user", ID: "martha"}) {
+		t.Fatalf("expected the query parameter to identify the user, got %+v", got)
+	}
+	r.Header.Set("X-Agent-ID", "arga")
+	if got := wsActor(r); got != (actor{Type: "agent", ID: "arga"}) {
+		t.Fatalf("expected the header to win, got %+v", got)
+	}
+}
+
+func TestWSCheckOrigin(t *testing.T) {
+	for origin, want := range map[string]bool{
+		"":                             true,
+		"https://heista-hq.vercel.app": true,
+		"http://example.com":           true,
+		"https://evil.example.net":     false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/api/ws", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		if got := wsCheckOrigin(r); got != want {
+			t.Errorf("origin %q: expected %v, got %v", origin, want, got)
+		}
+	}
+}
+
+func TestWebSocketRequiresDatabase(t *testing.T) {
+	s := &server{}
+	w := httptest.NewRecorder()
+	s.webSocket(w, httptest.NewRequest(http.MethodGet, "/api/ws?user_id=martha", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+}
+
+func TestWSHubPresence(t *testing.T) {
+	h := newWSHub()
+	a, b, c := newWSConn(actor{Type: "user", ID: "martha"}), newWSConn(actor{Type: "user", ID: "martha"}), newWSConn(actor{Type: "agent", ID: "arga"})
+	for _, conn := range []*wsConn{a, b, c} {
+		h.add(conn)
+		if err := h.join(conn, "logs"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := h.viewers("logs"); len(got) != 2 || got[0].ID != "arga" {
+		t.Fatalf("expected each viewer once, got %+v", got)
+	}
+
+	h.remove(c)
+	if got := h.viewers("logs"); len(got) != 1 || got[0].ID != "martha" {
+		t.Fatalf("expected the agent to have left, got %+v", got)
+	}
+	if msg := string(<-a.send); !strings.Contains(msg, `"type":"presence"`) {
+		t.Fatalf("expected a presence update, got %s", msg)
+	}
+}
+
+func TestWSConnDropsSlowClients(t *testing.T) {
+	c := newWSConn(actor{Type: "user", ID: "martha"})
+	for range wsSendBuffer + 1 {
+		c.enqueue([]byte("{}"))
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("expected the connection to be closed")
+	}
+	if c.closeCode != websocket.CloseTryAgainLater {
+		t.Fatalf("expected close code %d, got %d", websocket.CloseTryAgainLater, c.closeCode)
+	}
+}
+
+func TestServeWS(t *testing.T) {
+	s := &server{sockets: newWSHub()}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.serveWS(conn, wsActor(r))
+	}))
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?user_id=martha", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	read := func() wsMessage {
+		t.Helper()
+		var msg wsMessage
+		if err := client.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	if msg := read(); msg.Type != "welcome" || msg.Actor == nil || msg.Actor.ID != "martha" {
+		t.Fatalf("expected a welcome, got %+v", msg)
+	}
+	client.WriteJSON(wsMessage{Type: "subscribe", ID: "1", Topic: "logs"})
+	if msg := read(); msg.Type != "subscribed" || msg.ID != "1" || len(msg.Viewers) != 1 {
+		t.Fatalf("expected the subscription to be confirmed, got %+v", msg)
+	}
+	if msg := read(); msg.Type != "presence" || msg.Topic != "logs" {
+		t.Fatalf("expected a presence update, got %+v", msg)
+	}
+	client.WriteJSON(wsMessage{Type: "subscribe", ID: "2", Topic: "tasks"})
+	if msg := read(); msg.Type != "error" || msg.ID != "2" {
+		t.Fatalf("expected an unknown topic to be rejected, got %+v", msg)
+	}
+	client.WriteJSON(wsMessage{Type: "ping", ID: "3"})
+	if msg := read(); msg.Type != "pong" || msg.ID != "3" {
+		t.Fatalf("expected a pong, got %+v", msg)
+	}
+
+	s.sockets.broadcast("logs", wsMessage{Type: "event", Event: &changeEvent{Table: "logs", Op: "insert", ID: "l1"}})
+	if msg := read(); msg.Type != "event" || msg.Topic != "logs" || msg.Event.ID != "l1" {
+		t.Fatalf("expected the event, got %+v", msg)
+	}
+}
+
+func TestServeWSClosesOnShutdown(t *testing.T) {
+	s := &server{sockets: newWSHub(), closing: make(chan struct{})}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := wsUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		s.serveWS(conn, wsActor(r))
+	}))
+	defer srv.Close()
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?user_id=martha", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var welcome wsMessage
+	if err := client.ReadJSON(&welcome); err != nil {
+		t.Fatal(err)
+	}
+
+	close(s.closing)
+	_, _, err = client.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("expected a going-away close on shutdown, got %v", err)
+	}
+}
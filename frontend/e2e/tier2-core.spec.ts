@@ -8,7 +8,7 @@ test.describe('Tier-2 core flows', () => {
 
     await page.goto('/tasks')
 
-    const todoColumn = page.getByTestId('column-col-1')
+    const todoColumn = page.getByTestId('column-to-do')
     await expect(todoColumn).toBeVisible()
 
     await todoColumn.getByTestId('add-card-trigger').click()
@@ -18,20 +18,20 @@ test.describe('Tier-2 core flows', () => {
     const createdCard = page.getByTestId(`task-card-${cardTitle}`)
     await expect(createdCard).toBeVisible()
 
-    const inProgressColumn = page.getByTestId('column-col-2')
+    const inProgressColumn = page.getByTestId('column-in-progress')
     await dragCardToColumn(page, createdCard, inProgressColumn)
 
     await expect(inProgressColumn.getByTestId(`task-card-${cardTitle}`)).toBeVisible()
 
     await page.reload()
-    await expect(page.getByTestId('column-col-2').getByTestId(`task-card-${cardTitle}`)).toBeVisible()
+    await expect(page.getByTestId('column-in-progress').getByTestId(`task-card-${cardTitle}`)).toBeVisible()
   })
 
   test('logs: new activity log is visible after task action', async ({ page }) => {
     const logCard = `${TEST_PREFIX}-log-card`
 
     await page.goto('/tasks')
-    const todoColumn = page.getByTestId('column-col-1')
+    const todoColumn = page.getByTestId('column-to-do')
     await todoColumn.getByTestId('add-card-trigger').click()
     await todoColumn.getByTestId('new-task-input').fill(logCard)
     await todoColumn.getByTestId('confirm-add-task').click()
@@ -44,18 +44,10 @@ interface Column {
 
 let tempTaskCounter = 0
 
-const INITIAL_COLUMNS: Column[] = [
-  { id: "col-1", title: "To Do", tasks: [] },
-  { id: "col-2", title: "In Progress", tasks: [] },
-  { id: "col-3", title: "Done", tasks: [] },
-]
-
-const COLUMN_IDS = INITIAL_COLUMNS.map((column) => column.id)
-
 const toTestIdSafe = (value: string) => value.replace(/\s+/g, '-').toLowerCase()
 
 export function TrelloBoard() {
-  const [columns, setColumns] = useState<Column[]>(INITIAL_COLUMNS)
+  const [columns, setColumns] = useState<Column[]>([])
   const [activeTask, setActiveTask] = useState<Task | null>(null)
   const [addingToColumn, setAddingToColumn] = useState<string | null>(null)
   const [newTaskTitle, setNewTaskTitle] = useState("")
@@ -82,14 +74,16 @@ export function TrelloBoard() {
 
   useEffect(() => {
     const fetchData = async () => {
-      const { data, error } = await boardApi.getTasks(COLUMN_IDS)
+      // Tasks arrive in board order within each column.
+      const { data, error } = await boardApi.loadBoard()
       if (data && !error) {
-        setColumns(prev => prev.map(col => ({
-          ...col,
-          tasks: data
-            .filter((t) => t.column_id === col.id)
-            .sort((a, b) => a.order - b.order)
+        setColumns(data.columns.map(col => ({
+          id: col.id,
+          title: col.title,
+          tasks: col.tasks,
         })))
+      } else {
+        console.error("Failed to load board:", error)
       }
     }
     fetchData()
@@ -239,7 +233,7 @@ export function TrelloBoard() {
         onDragEnd={handleDragEnd}
       >
         {columns.map((column) => (
-          <div key={column.id} className="w-80 flex-shrink-0" data-testid={`column-${column.id}`}>
+          <div key={column.id} className="w-80 flex-shrink-0" data-testid={`column-${toTestIdSafe(column.title)}`}>
             <Card className="bg-muted border-none shadow-none h-fit">
               <CardHeader className="p-3 flex flex-row items-center justify-between">
                 <CardTitle className="text-sm font-bold text-foreground">{column.title}</CardTitle>
@@ -8,6 +8,23 @@ export interface Task {
   order: number
 }
 
+export interface BoardColumn {
+  id: string
+  board_id: string
+  title: string
+  order: number
+  tasks: Task[]
+}
+
+export interface Board {
+  id: string
+  name: string
+  columns: BoardColumn[]
+}
+
+// Name of the board created on first load when the API has none yet.
+export const DEFAULT_BOARD_NAME = 'Main board'
+
 export interface AgentLog {
   id: string
   agent_id: string
@@ -41,6 +58,26 @@ async function toResult<T>(action: () => Promise<T>) {
 }
 
 export const boardApi = {
+  // Loads the oldest board with its columns and tasks. Column ids are the
+  // API's UUIDs, so tasks can be created and moved with them.
+  loadBoard: async () => {
+    return toResult(async () => {
+      const boards = unwrapArray<{ id: string }>(await apiRequest<unknown>('/api/boards'))
+      let id = boards[0]?.id
+      if (!id) {
+        const created = await apiRequest<unknown>('/api/boards', {
+          method: 'POST',
+          body: { name: DEFAULT_BOARD_NAME },
+        })
+        id = unwrapObject<{ id: string }>(created)?.id
+      }
+      if (!id) throw new Error('Board could not be created')
+      const board = unwrapObject<Board>(await apiRequest<unknown>(`/api/boards/${id}`))
+      if (!board) throw new Error('Board not found')
+      return board
+    })
+  },
+
   getTasks: async (columnIds: string[]) => {
     return toResult(async () => {
       const query = columnIds.length > 0 ? `?columnIds=${encodeURIComponent(columnIds.join(','))}` : ''
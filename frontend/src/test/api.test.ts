@@ -19,6 +19,34 @@ describe('Board API', () => {
     expect(data).toEqual(mockTasks)
   })
 
+  it('should load the first board with its columns', async () => {
+    const board = {
+      id: 'b1',
+      name: 'Main board',
+      columns: [{ id: 'c1', board_id: 'b1', title: 'To Do', order: 0, tasks: [] }],
+    }
+    const fetchMock = vi.spyOn(global, 'fetch')
+      .mockResolvedValueOnce({ ok: true, text: vi.fn().mockResolvedValue('[{"id":"b1"}]') } as unknown as Response)
+      .mockResolvedValueOnce({ ok: true, text: vi.fn().mockResolvedValue(JSON.stringify(board)) } as unknown as Response)
+
+    const { data, error } = await boardApi.loadBoard()
+    expect(error).toBeNull()
+    expect(data?.columns[0].id).toBe('c1')
+    expect(String(fetchMock.mock.calls[1][0])).toMatch(/\/api\/boards\/b1$/)
+  })
+
+  it('should create a board when none exists', async () => {
+    const fetchMock = vi.spyOn(global, 'fetch')
+      .mockResolvedValueOnce({ ok: true, text: vi.fn().mockResolvedValue('[]') } as unknown as Response)
+      .mockResolvedValueOnce({ ok: true, text: vi.fn().mockResolvedValue('{"id":"b2"}') } as unknown as Response)
+      .mockResolvedValueOnce({ ok: true, text: vi.fn().mockResolvedValue('{"id":"b2","name":"Main board","columns":[]}') } as unknown as Response)
+
+    const { data, error } = await boardApi.loadBoard()
+    expect(error).toBeNull()
+    expect(data?.id).toBe('b2')
+    expect(fetchMock.mock.calls[1][1]?.method).toBe('POST')
+  })
+
   it('should create log entry through Go API', async () => {
     const mockLog = {
       id: 'log-1',